@@ -4,8 +4,10 @@ import (
 	"flag"
 	"net/url"
 	"os"
+	"time"
 
 	"github.com/caarlos0/env/v6"
+	"github.com/ikashurnikov/shortener/internal/app/model"
 )
 
 type Config struct {
@@ -13,6 +15,78 @@ type Config struct {
 	BaseURL         url.URL `env:"BASE_URL" envDefault:"http://localhost:8080"`
 	FileStoragePath string  `env:"FILE_STORAGE_PATH"`
 	DatabaseDSN     string  `env:"DATABASE_DSN"`
+	// TrashRetention Срок хранения удаленных ссылок в корзине.
+	TrashRetention time.Duration `env:"TRASH_RETENTION" envDefault:"720h"`
+	// TrashPurgeInterval Период очистки корзины.
+	TrashPurgeInterval time.Duration `env:"TRASH_PURGE_INTERVAL" envDefault:"1h"`
+	// DedupMode Режим дедупликации оригинальных ссылок: global, per-user или none.
+	DedupMode model.DedupMode `env:"DEDUP_MODE" envDefault:"global"`
+	// URLCanonicalization Правила приведения оригинальных ссылок к каноническому виду через запятую
+	// (см. model.URLCanonicalization), none - без приведения. По умолчанию применяются только правила,
+	// не меняющие ресурс ссылки; strip-fragment и sort-query включаются явно.
+	URLCanonicalization model.URLCanonicalization `env:"URL_CANONICALIZATION" envDefault:"lowercase-host,strip-default-port,resolve-dot-segments,normalize-escapes,punycode"`
+	// StripParams Параметры отслеживания, удаляемые из ссылок всех пользователей (см. model.RewriteRule.Strip),
+	// например utm_*,fbclid,gclid,yclid,msclkid. По умолчанию параметры не удаляются.
+	StripParams []string `env:"STRIP_PARAMS"`
+	// RewriteRulesFile JSON-файл со списком правил model.RewriteRule, применяемых после StripParams.
+	RewriteRulesFile string `env:"REWRITE_RULES_FILE"`
+	// AllowedSchemes Схемы, разрешенные в оригинальных ссылках.
+	AllowedSchemes []string `env:"ALLOWED_SCHEMES" envDefault:"http,https"`
+	// AllowPrivateDestinations Разрешить ссылки на локальные и частные адреса.
+	AllowPrivateDestinations bool `env:"ALLOW_PRIVATE_DESTINATIONS" envDefault:"false"`
+	// ResolveDestinations Проверять адреса, в которые разрешаются хосты оригинальных ссылок.
+	ResolveDestinations bool `env:"RESOLVE_DESTINATIONS" envDefault:"false"`
+	// AllowedDomains Домены, на которые разрешены ссылки. Пустой список разрешает любой домен.
+	AllowedDomains []string `env:"ALLOWED_DOMAINS"`
+	// DeniedDomains Домены, на которые запрещены ссылки.
+	DeniedDomains []string `env:"DENIED_DOMAINS"`
+	// RejectSelfLinks Отклонять короткие ссылки сервиса вместо замены их оригинальными ссылками.
+	RejectSelfLinks bool `env:"REJECT_SELF_LINKS" envDefault:"false"`
+	// ResolveShorteners Раскрывать ссылки других сокращателей и сохранять конечную ссылку.
+	ResolveShorteners bool `env:"RESOLVE_SHORTENERS" envDefault:"false"`
+	// ShortenerDomains Домены сокращателей, ссылки которых раскрываются.
+	ShortenerDomains []string `env:"SHORTENER_DOMAINS" envDefault:"bit.ly,t.co,goo.gl,tinyurl.com,ow.ly,is.gd,buff.ly,cutt.ly,rebrand.ly,clck.ru"`
+	// ResolveTimeout Время ожидания ответа сокращателя.
+	ResolveTimeout time.Duration `env:"RESOLVE_TIMEOUT" envDefault:"5s"`
+	// BlocklistHostsFile Файл фишинговых и вредоносных хостов, по одному хосту в строке. Хост блокирует и поддомены.
+	BlocklistHostsFile string `env:"BLOCKLIST_HOSTS_FILE"`
+	// BlocklistPrefixesFile Файл фишинговых и вредоносных ссылок, по одному префиксу ссылки в строке.
+	BlocklistPrefixesFile string `env:"BLOCKLIST_PREFIXES_FILE"`
+	// BlocklistReloadInterval Период проверки изменений файлов блокировки.
+	BlocklistReloadInterval time.Duration `env:"BLOCKLIST_RELOAD_INTERVAL" envDefault:"1m"`
+	// GeoIPDatabaseFile База в формате MaxMind DB (например GeoLite2-Country.mmdb), по которой определяется
+	// страна посетителя для правил перехода и статистики. Если не задана, страна не определяется.
+	GeoIPDatabaseFile string `env:"GEOIP_DATABASE_FILE"`
+	// QRCodeCacheSize Кол-во изображений QR-кодов, хранящихся в памяти. 0 отключает кеш.
+	QRCodeCacheSize int `env:"QR_CODE_CACHE_SIZE" envDefault:"1024"`
+	// DefaultRedirectStatus Код перенаправления ссылок, для которых он не задан: 301, 302, 307 или 308.
+	DefaultRedirectStatus int `env:"DEFAULT_REDIRECT_STATUS" envDefault:"307"`
+	// PermanentRedirectMaxAge Время кеширования постоянных перенаправлений (301, 308) браузерами и CDN.
+	PermanentRedirectMaxAge time.Duration `env:"PERMANENT_REDIRECT_MAX_AGE" envDefault:"24h"`
+	// CORSAllowedOrigins Источники, с которых браузер может обращаться к методам /api/*. Запросы с перечисленных
+	// источников выполняются с cookie пользователя, "*" разрешает любой источник без cookie.
+	CORSAllowedOrigins []string `env:"CORS_ALLOWED_ORIGINS" envDefault:"*"`
+	// AdminToken Токен доступа к административным методам /api/admin. Если не задан, методы недоступны.
+	AdminToken string `env:"ADMIN_TOKEN"`
+	// LinkIDEncoder Формат коротких ссылок: zbase32 (последовательные коды), feistel (непоследовательные коды)
+	// или base62 (коды минимальной длины).
+	LinkIDEncoder string `env:"LINK_ID_ENCODER" envDefault:"zbase32"`
+	// LinkIDAlphabet Алфавит кодов в формате base62.
+	LinkIDAlphabet string `env:"LINK_ID_ALPHABET" envDefault:"0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"`
+	// LinkIDSecret Секрет, которым перемешиваются коды в формате feistel.
+	LinkIDSecret string `env:"LINK_ID_SECRET"`
+	// LinkIDChecksum Добавлять к кодам контрольный символ.
+	LinkIDChecksum bool `env:"LINK_ID_CHECKSUM" envDefault:"false"`
+	// LinkIDAcceptUnchecked Принимать коды без контрольного символа, выданные до включения LinkIDChecksum.
+	// Опечатка в таком коде может привести на чужую ссылку. В формате base62 коды без контрольного символа
+	// не отличить от кодов с ним по длине, поэтому вместе с LINK_ID_CHECKSUM его нужно отключить.
+	LinkIDAcceptUnchecked bool `env:"LINK_ID_ACCEPT_UNCHECKED" envDefault:"true"`
+	// ReservedWords Коды, которые не выдаются новым ссылкам, например совпадающие с маршрутами сервиса.
+	ReservedWords []string `env:"RESERVED_WORDS" envDefault:"api,ping,admin,health,static"`
+	// BlockedWords Слова, которые не должны встречаться в кодах, в дополнение к встроенному списку.
+	BlockedWords []string `env:"BLOCKED_WORDS"`
+	// BlockedWordsFile Файл с запрещенными словами, по одному слову в строке.
+	BlockedWordsFile string `env:"BLOCKED_WORDS_FILE"`
 }
 
 func LoadConfig() (Config, error) {
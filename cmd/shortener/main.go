@@ -1,10 +1,14 @@
 package main
 
 import (
+	"encoding/json"
+	"github.com/ikashurnikov/shortener/internal/app/model"
 	"github.com/ikashurnikov/shortener/internal/app/repo"
 	"github.com/ikashurnikov/shortener/internal/app/service"
 	"log"
+	"net"
 	"net/http"
+	"os"
 
 	"github.com/ikashurnikov/shortener/internal/app/handler"
 )
@@ -15,37 +19,181 @@ func main() {
 		log.Fatal(err)
 	}
 
-	repo := newRepo(&cfg)
+	linkIDEncoder := newLinkIDEncoder(&cfg)
+	repo := newRepo(&cfg, newWordFilter(&cfg).LinkIDFilter(linkIDEncoder))
 	defer repo.Close()
 
+	purger := newTrashPurger(&cfg, repo)
+	purger.Start()
+	defer purger.Stop()
+
 	server := http.Server{
 		Addr: cfg.SrvAddr,
 	}
-	m := service.NewShortener(repo, cfg.BaseURL)
+	opts := []service.Option{
+		service.WithLinkIDEncoder(linkIDEncoder),
+		service.WithURLCanonicalization(cfg.URLCanonicalization),
+		service.WithRewriteRules(newRewriteRules(&cfg)),
+		service.WithDestinationPolicy(newDestinationPolicy(&cfg)),
+		service.WithRejectSelfLinks(cfg.RejectSelfLinks),
+		service.WithQRCodeRenderer(service.NewQRCodeRenderer(cfg.QRCodeCacheSize)),
+		service.WithDefaultRedirectStatus(newDefaultRedirectStatus(&cfg)),
+	}
+	if cfg.ResolveShorteners {
+		client := &http.Client{Timeout: cfg.ResolveTimeout}
+		opts = append(opts, service.WithURLResolver(service.NewShortenerResolver(client, cfg.ShortenerDomains)))
+	}
+	if blocklist := newBlocklist(&cfg); blocklist != nil {
+		blocklist.Start(cfg.BlocklistReloadInterval)
+		defer blocklist.Stop()
+		opts = append(opts, service.WithBlocklist(blocklist))
+	}
+	if cfg.GeoIPDatabaseFile != "" {
+		geoIP, err := service.NewGeoIP(cfg.GeoIPDatabaseFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer geoIP.Close()
+		opts = append(opts, service.WithGeoIP(geoIP))
+	}
+	m := service.NewShortener(repo, cfg.BaseURL, opts...)
 
-	h := handler.NewHandler(m, "secret")
+	h := handler.NewHandler(m, "secret", cfg.AdminToken)
+	if cfg.PermanentRedirectMaxAge < 0 {
+		log.Fatal("invalid permanent redirect max age")
+	}
+	h.PermanentRedirectMaxAge = cfg.PermanentRedirectMaxAge
+	h.CORSAllowedOrigins = cfg.CORSAllowedOrigins
 	defer h.Shutdown()
 
 	server.Handler = h
 	log.Fatal(server.ListenAndServe())
 }
 
-func newRepo(cfg *Config) repo.Repo {
+func newTrashPurger(cfg *Config, r repo.Repo) *repo.TrashPurger {
+	if cfg.TrashRetention < 0 || cfg.TrashPurgeInterval <= 0 {
+		log.Fatal("invalid trash retention settings")
+	}
+	return repo.NewTrashPurger(r, cfg.TrashRetention, cfg.TrashPurgeInterval)
+}
+
+func newLinkIDEncoder(cfg *Config) service.LinkIDEncoder {
+	encoder, err := service.NewLinkIDEncoder(service.LinkIDEncoderConfig{
+		Format:          cfg.LinkIDEncoder,
+		Alphabet:        cfg.LinkIDAlphabet,
+		Secret:          cfg.LinkIDSecret,
+		Checksum:        cfg.LinkIDChecksum,
+		AcceptUnchecked: cfg.LinkIDAcceptUnchecked,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	return encoder
+}
+
+func newDestinationPolicy(cfg *Config) model.DestinationPolicy {
+	policy := model.DestinationPolicy{
+		AllowedSchemes:  cfg.AllowedSchemes,
+		RequireHost:     true,
+		AllowPrivateIPs: cfg.AllowPrivateDestinations,
+		AllowedDomains:  cfg.AllowedDomains,
+		DeniedDomains:   cfg.DeniedDomains,
+	}
+	if cfg.ResolveDestinations {
+		policy.LookupIP = net.LookupIP
+	}
+	return policy
+}
+
+// newRewriteRules Загружает правила изменения оригинальных ссылок.
+func newRewriteRules(cfg *Config) model.RewriteRules {
+	var rules model.RewriteRules
+	if len(cfg.StripParams) > 0 {
+		rules = append(rules, model.RewriteRule{Strip: cfg.StripParams})
+	}
+
+	if cfg.RewriteRulesFile != "" {
+		data, err := os.ReadFile(cfg.RewriteRulesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var fileRules model.RewriteRules
+		if err = json.Unmarshal(data, &fileRules); err != nil {
+			log.Fatal(err)
+		}
+		rules = append(rules, fileRules...)
+	}
+
+	if err := rules.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	return rules
+}
+
+// newWordFilter Создает фильтр кодов новых ссылок.
+func newWordFilter(cfg *Config) *service.WordFilter {
+	filter := service.NewWordFilter(cfg.ReservedWords, append(service.DefaultBlockedWords(), cfg.BlockedWords...))
+	if cfg.BlockedWordsFile == "" {
+		return filter
+	}
+
+	file, err := os.Open(cfg.BlockedWordsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	if err = filter.ReadBlockedWords(file); err != nil {
+		log.Fatal(err)
+	}
+	return filter
+}
+
+// newBlocklist Загружает список фишинговых и вредоносных адресов. Возвращает nil, если файлы не заданы.
+func newBlocklist(cfg *Config) *service.Blocklist {
+	if cfg.BlocklistHostsFile == "" && cfg.BlocklistPrefixesFile == "" {
+		return nil
+	}
+	if cfg.BlocklistReloadInterval <= 0 {
+		log.Fatal("invalid blocklist reload interval")
+	}
+
+	blocklist, err := service.NewBlocklist(cfg.BlocklistHostsFile, cfg.BlocklistPrefixesFile, cfg.URLCanonicalization)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return blocklist
+}
+
+func newRepo(cfg *Config, linkIDFilter func(model.LinkID) bool) repo.Repo {
+	opts := []repo.Option{
+		repo.WithDedupMode(cfg.DedupMode),
+		repo.WithLinkIDFilter(linkIDFilter),
+	}
+
 	switch {
 	case cfg.DatabaseDSN != "":
-		db, err := repo.NewDBRepo(cfg.DatabaseDSN)
+		db, err := repo.NewDBRepo(cfg.DatabaseDSN, opts...)
 		if err != nil {
 			log.Fatal(err)
 		}
 		return db
 
 	case cfg.FileStoragePath != "":
-		fileStorage, err := repo.NewFileRepo(cfg.FileStoragePath)
+		fileStorage, err := repo.NewFileRepo(cfg.FileStoragePath, opts...)
 		if err != nil {
 			log.Fatal(err)
 		}
 		return fileStorage
 	}
 
-	return repo.NewInMemoryRepo()
+	return repo.NewInMemoryRepo(opts...)
+}
+
+func newDefaultRedirectStatus(cfg *Config) int {
+	if !model.IsRedirectStatus(cfg.DefaultRedirectStatus) {
+		log.Fatalf("invalid default redirect status: %d", cfg.DefaultRedirectStatus)
+	}
+	return cfg.DefaultRedirectStatus
 }
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// requireAdmin Пропускает только запросы с заголовком "Authorization: Bearer <AdminToken>".
+// Если токен администратора не задан, административные методы недоступны.
+func (h *Handler) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if h.AdminToken == "" {
+			http.NotFound(rw, req)
+			return
+		}
+
+		auth := req.Header.Get("Authorization")
+		token := strings.TrimPrefix(auth, "Bearer ")
+		if token == auth || subtle.ConstantTimeCompare([]byte(token), []byte(h.AdminToken)) != 1 {
+			rw.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(rw, "admin token required", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// GET /api/admin/blocklist/links
+func (h *Handler) getBlockedLinks(rw http.ResponseWriter, _ *http.Request) {
+	links, err := h.shortener.GetBlockedLinks()
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.writeJSON(rw, links)
+}
+
+// GET /api/admin/reports
+func (h *Handler) getReports(rw http.ResponseWriter, _ *http.Request) {
+	reports, err := h.shortener.GetReports()
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.writeJSON(rw, reports)
+}
+
+// POST /api/admin/links/{shortURL}/disable
+func (h *Handler) postDisableLink(rw http.ResponseWriter, req *http.Request) {
+	h.setLinkDisabled(rw, req, true)
+}
+
+// POST /api/admin/links/{shortURL}/restore
+func (h *Handler) postRestoreLink(rw http.ResponseWriter, req *http.Request) {
+	h.setLinkDisabled(rw, req, false)
+}
+
+func (h *Handler) setLinkDisabled(rw http.ResponseWriter, req *http.Request, disabled bool) {
+	if err := h.shortener.SetLinkDisabled(chi.URLParam(req, "shortURL"), disabled); err != nil {
+		http.Error(rw, err.Error(), userLinkErrorStatus(err))
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
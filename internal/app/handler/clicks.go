@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"log"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+)
+
+const (
+	// clicksQueueSize Кол-во переходов, ожидающих записи в хранилище.
+	clicksQueueSize = 1024
+	// clickWorkersCount Кол-во горутин, записывающих переходы в хранилище.
+	clickWorkersCount = 4
+)
+
+type clickEvent struct {
+	code  string
+	click model.Click
+}
+
+// startClickWorkers Запускает запись переходов из очереди в хранилище.
+func (h *Handler) startClickWorkers() {
+	h.clicks = make(chan clickEvent, clicksQueueSize)
+	for i := 0; i < clickWorkersCount; i++ {
+		h.clickWorkers.Add(1)
+		go func() {
+			defer h.clickWorkers.Done()
+			for event := range h.clicks {
+				if err := h.shortener.CountClick(event.code, event.click); err != nil {
+					log.Printf("counting click on %q failed: %v", event.code, err)
+				}
+			}
+		}()
+	}
+}
+
+// countClick Ставит переход в очередь записи. Не блокирует перенаправление: если очередь заполнена,
+// переход не учитывается.
+func (h *Handler) countClick(code string, click model.Click) {
+	select {
+	case h.clicks <- clickEvent{code: code, click: click}:
+	default:
+		log.Printf("clicks queue is full, click on %q dropped", code)
+	}
+}
+
+// stopClickWorkers Дожидается записи переходов из очереди.
+func (h *Handler) stopClickWorkers() {
+	close(h.clicks)
+	h.clickWorkers.Wait()
+}
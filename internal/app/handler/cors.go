@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// corsMaxAge Время в секундах, на которое браузер кеширует ответ на предварительный запрос.
+const corsMaxAge = "600"
+
+// corsMethods Методы, для которых ищутся маршруты при ответе на OPTIONS.
+var corsMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPatch, http.MethodDelete}
+
+// cors Добавляет заголовки CORS к ответам методов /api/* и отвечает на OPTIONS, в том числе на предварительные
+// запросы браузера. Запросы с источников из CORSAllowedOrigins выполняются с cookie пользователя,
+// "*" разрешает запросы с любого источника, но без cookie.
+func (h *Handler) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.URL.Path, "/api/") {
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		header := rw.Header()
+		header.Add("Vary", "Origin")
+		if origin := req.Header.Get("Origin"); origin != "" {
+			h.setCORSOrigin(header, origin)
+		}
+
+		if req.Method != http.MethodOptions {
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		methods := h.routeMethods(req.URL.Path)
+		if len(methods) == 0 {
+			http.NotFound(rw, req)
+			return
+		}
+		allow := strings.Join(append(methods, http.MethodOptions), ", ")
+		header.Set("Allow", allow)
+
+		if req.Header.Get("Access-Control-Request-Method") != "" {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", allow)
+			if headers := req.Header.Get("Access-Control-Request-Headers"); headers != "" {
+				header.Set("Access-Control-Allow-Headers", headers)
+			}
+			header.Set("Access-Control-Max-Age", corsMaxAge)
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (h *Handler) setCORSOrigin(header http.Header, origin string) {
+	for _, allowed := range h.CORSAllowedOrigins {
+		switch {
+		case strings.EqualFold(allowed, origin):
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Set("Access-Control-Allow-Credentials", "true")
+			return
+		case allowed == "*":
+			header.Set("Access-Control-Allow-Origin", "*")
+		}
+	}
+}
+
+// routeMethods Возвращает методы, для которых зарегистрирован маршрут path.
+func (h *Handler) routeMethods(path string) []string {
+	var methods []string
+	for _, method := range corsMethods {
+		if h.Mux.Match(chi.NewRouteContext(), method, path) {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
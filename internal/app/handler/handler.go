@@ -3,40 +3,66 @@ package handler
 import (
 	"compress/flate"
 	"compress/gzip"
+	"crypto/rand"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/ikashurnikov/shortener/internal/app/model"
 	"github.com/ikashurnikov/shortener/internal/app/service"
-	"golang.org/x/exp/slices"
 	"golang.org/x/sync/errgroup"
 	"io"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Handler struct {
 	*chi.Mux
 	shortener service.Shortener
 	workers   errgroup.Group
-	CipherKey string
+	// clicks Очередь переходов, которые записываются в хранилище вне обработки перенаправления.
+	clicks       chan clickEvent
+	clickWorkers sync.WaitGroup
+	CipherKey    string
+	// AdminToken Токен доступа к административным методам /api/admin. Пустой токен отключает их.
+	AdminToken string
+	// PermanentRedirectMaxAge Время кеширования постоянных перенаправлений (301, 308) браузерами и CDN.
+	// 0 запрещает кеширование.
+	PermanentRedirectMaxAge time.Duration
+	// CORSAllowedOrigins Источники, с которых браузер может обращаться к методам /api/*. "*" - любой источник.
+	CORSAllowedOrigins []string
 }
 
 const (
 	workersCount = 10
+	// visitorIDMaxAge Время хранения идентификатора посетителя, по которому выбирается вариант ссылки.
+	visitorIDMaxAge = 365 * 24 * time.Hour
+	// DefaultPermanentRedirectMaxAge Время кеширования постоянных перенаправлений по умолчанию.
+	DefaultPermanentRedirectMaxAge = 24 * time.Hour
 )
 
-func NewHandler(shortener service.Shortener, cipherKey string) *Handler {
+func NewHandler(shortener service.Shortener, cipherKey string, adminToken string) *Handler {
 	router := chi.NewRouter()
 
 	handler := &Handler{
-		Mux:       router,
-		shortener: shortener,
-		CipherKey: cipherKey,
-		workers:   errgroup.Group{},
+		Mux:        router,
+		shortener:  shortener,
+		CipherKey:  cipherKey,
+		AdminToken: adminToken,
+		workers:    errgroup.Group{},
+
+		PermanentRedirectMaxAge: DefaultPermanentRedirectMaxAge,
 	}
 	handler.workers.SetLimit(workersCount)
+	handler.startClickWorkers()
 
 	compressor := middleware.NewCompressor(flate.BestCompression)
 
@@ -45,6 +71,7 @@ func NewHandler(shortener service.Shortener, cipherKey string) *Handler {
 	router.Use(middleware.RealIP)
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.Logger)
+	router.Use(handler.cors)
 	router.Use(decompressHandler)
 	router.Use(compressor.Handler)
 
@@ -53,16 +80,34 @@ func NewHandler(shortener service.Shortener, cipherKey string) *Handler {
 		router.Post("/api/shorten", handler.postAPIShorten)
 		router.Post("/api/shorten/batch", handler.postAPIShortenBatch)
 		router.Get("/api/user/urls", handler.getUserURLs)
+		router.Get("/api/user/urls/trash", handler.getUserTrash)
+		router.Post("/api/user/urls/restore", handler.postRestoreURLs)
+		router.Patch("/api/user/urls/{shortURL}", handler.patchUserURL)
+		router.Get("/api/user/urls/{shortURL}/history", handler.getUserURLHistory)
+		router.Post("/api/user/urls/{shortURL}/rollback", handler.postUserURLRollback)
 		router.Get("/{shortURL}", handler.getShortLink)
+		router.Head("/{shortURL}", handler.getShortLink)
+		router.Post("/{shortURL}/report", handler.postReport)
+		router.Get("/{shortURL}/qr.png", handler.getQRCodePNG)
+		router.Get("/{shortURL}/qr.svg", handler.getQRCodeSVG)
 		router.Delete("/api/user/urls", handler.deleteURLs)
 		router.Get("/ping", handler.ping)
 	})
 
+	handler.Route("/api/admin", func(router chi.Router) {
+		router.Use(handler.requireAdmin)
+		router.Get("/blocklist/links", handler.getBlockedLinks)
+		router.Get("/reports", handler.getReports)
+		router.Post("/links/{shortURL}/disable", handler.postDisableLink)
+		router.Post("/links/{shortURL}/restore", handler.postRestoreLink)
+	})
+
 	return handler
 }
 
 func (h *Handler) Shutdown() {
 	_ = h.workers.Wait()
+	h.stopClickWorkers()
 }
 
 // POST /
@@ -91,20 +136,103 @@ func (h *Handler) postLongLink(rw http.ResponseWriter, req *http.Request) {
 }
 
 // GET /{shortURL}
+// GET /{shortURL}+ или /{shortURL}?preview - страница предпросмотра ссылки вместо перенаправления.
+// HEAD /{shortURL} - тот же ответ без тела и без учета перехода, для проверки ссылок.
 func (h *Handler) getShortLink(rw http.ResponseWriter, req *http.Request) {
 	shortURL := chi.URLParam(req, "shortURL")
-	link, err := h.shortener.GetLinkByShortURL(shortURL)
+	code := strings.TrimSuffix(shortURL, "+")
+	preview := code != shortURL || req.URL.Query().Has("preview")
+
+	visitorID, knownVisitor := h.getVisitorID(req)
+	visit := model.Visit{
+		UserAgent:      req.UserAgent(),
+		AcceptLanguage: req.Header.Get("Accept-Language"),
+		Query:          req.URL.Query(),
+		VisitorID:      visitorID,
+		IP:             remoteIP(req),
+	}
+	link, err := h.shortener.GetLinkByShortURL(code, visit)
 
 	if err != nil {
-		status := http.StatusBadRequest
-		if errors.Is(err, model.ErrLinkRemoved) {
-			status = http.StatusGone
+		switch {
+		case errors.Is(err, model.ErrNonCanonicalCode):
+			target := link.ShortURL + strings.TrimPrefix(shortURL, code)
+			if req.URL.RawQuery != "" {
+				target += "?" + req.URL.RawQuery
+			}
+			http.Redirect(rw, req, target, http.StatusMovedPermanently)
+		case errors.Is(err, model.ErrLinkRemoved), errors.Is(err, model.ErrLinkDisabled):
+			http.Error(rw, err.Error(), http.StatusGone)
+		case errors.Is(err, model.ErrBlockedDestination):
+			writeWarningPage(rw, link.ShortURL, link.OriginalURL)
+		case errors.Is(err, model.ErrInvalidChecksum):
+			msg := fmt.Sprintf("Short link %q contains a typo: please check the code and try again.", code)
+			http.Error(rw, msg, http.StatusNotFound)
+		default:
+			http.Error(rw, err.Error(), http.StatusBadRequest)
 		}
-		http.Error(rw, err.Error(), status)
 		return
 	}
 
-	http.Redirect(rw, req, link.OriginalURL, http.StatusTemporaryRedirect)
+	// Посетитель запоминается, чтобы при следующих переходах попадать на тот же вариант ссылки.
+	// HEAD-запросы (проверки ссылок) не считаются переходом, поэтому посетителя не запоминают.
+	if link.Variant != "" && !knownVisitor && req.Method != http.MethodHead {
+		NewSignedCookie(h.CipherKey).SetIntWithMaxAge(rw, "visitor_id", int(visitorID), visitorIDMaxAge)
+	}
+
+	if preview {
+		writePreviewPage(rw, link, 0)
+		return
+	}
+
+	if req.Method != http.MethodHead {
+		h.countClick(code, model.Click{Variant: link.Variant, Country: link.Country})
+	}
+
+	if link.Interstitial {
+		writePreviewPage(rw, link, interstitialCountdown)
+		return
+	}
+	h.redirect(rw, req, link)
+}
+
+// redirect Перенаправляет на оригинальную ссылку со статусом ссылки. Постоянные перенаправления
+// кешируются на PermanentRedirectMaxAge, временные не кешируются, чтобы каждый переход доходил до сервиса.
+// Ссылки с правилами перехода и вариантами не кешируются: оригинальная ссылка зависит от посетителя.
+func (h *Handler) redirect(rw http.ResponseWriter, req *http.Request, link model.Link) {
+	status := link.RedirectStatus
+	if !model.IsRedirectStatus(status) {
+		status = http.StatusTemporaryRedirect
+	}
+
+	header := rw.Header()
+	personalized := len(link.Routing) > 0 || len(link.Variants) > 0
+	if model.IsPermanentRedirect(status) && !personalized && h.PermanentRedirectMaxAge > 0 {
+		header.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int64(h.PermanentRedirectMaxAge.Seconds())))
+		header.Set("Expires", time.Now().Add(h.PermanentRedirectMaxAge).UTC().Format(http.TimeFormat))
+	} else {
+		header.Set("Cache-Control", "private, no-cache, no-store, max-age=0")
+		header.Set("Expires", time.Unix(0, 0).UTC().Format(http.TimeFormat))
+	}
+
+	http.Redirect(rw, req, link.OriginalURL, status)
+}
+
+// POST /{shortURL}/report
+func (h *Handler) postReport(rw http.ResponseWriter, req *http.Request) {
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.shortener.ReportLink(chi.URLParam(req, "shortURL"), request.Reason); err != nil {
+		http.Error(rw, err.Error(), userLinkErrorStatus(err))
+		return
+	}
+	rw.WriteHeader(http.StatusAccepted)
 }
 
 // POST /api/shorten
@@ -185,17 +313,11 @@ func (h *Handler) postAPIShortenBatch(rw http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	// Ссылки возвращаются в порядке запроса. Сопоставлять их по оригинальной ссылке нельзя:
+	// в режимах дедупликации per-user и none одна ссылка в пакете может получить несколько кодов.
 	reply := make([]Reply, len(links))
 	for i, link := range links {
-		idx := slices.IndexFunc(request, func(req Request) bool {
-			return link.OriginalURL == req.OriginalURL
-		})
-
-		if idx == -1 {
-			http.Error(rw, "internal error", http.StatusInternalServerError)
-			return
-		}
-		reply[i].CorrelationID = request[idx].CorrelationID
+		reply[i].CorrelationID = request[i].CorrelationID
 		reply[i].ShortURL = link.ShortURL
 	}
 
@@ -212,28 +334,120 @@ func (h *Handler) postAPIShortenBatch(rw http.ResponseWriter, req *http.Request)
 }
 
 // GET /api/user/urls
+// Параметры запроса:
+//   - limit  - размер страницы;
+//   - cursor - курсор страницы, полученный из заголовка Link предыдущего ответа;
+//   - order  - порядок сортировки по ID ссылки: asc (по умолчанию) или desc, см. model.SortOrder;
+//   - domain - фильтр по домену оригинальной ссылки;
+//   - q      - фильтр по подстроке оригинальной ссылки или заголовка;
+//   - tag    - фильтр по тегу.
 func (h *Handler) getUserURLs(rw http.ResponseWriter, req *http.Request) {
+	h.listUserURLs(rw, req, false)
+}
+
+// GET /api/user/urls/trash
+// Параметры запроса такие же, как у GET /api/user/urls.
+func (h *Handler) getUserTrash(rw http.ResponseWriter, req *http.Request) {
+	h.listUserURLs(rw, req, true)
+}
+
+func (h *Handler) listUserURLs(rw http.ResponseWriter, req *http.Request, trash bool) {
+	query, err := parseUserLinksQuery(req.URL.Query())
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.Trash = trash
+
 	uid := h.getUserID(req)
-	links, err := h.shortener.GetLinksByUserID(uid)
+	page, err := h.shortener.GetLinksByUserID(uid, query)
 
 	if err != nil {
 		http.Error(rw, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if len(links) == 0 {
+	if len(page.Links) == 0 {
 		rw.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
-	enc := json.NewEncoder(rw)
-	enc.SetEscapeHTML(false)
-	err = enc.Encode(links)
+	if page.NextCursor != "" {
+		next := *req.URL
+		values := next.Query()
+		values.Set("cursor", page.NextCursor)
+		next.RawQuery = values.Encode()
+		rw.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	}
+
+	h.writeJSON(rw, page.Links)
+}
+
+// POST /api/user/urls/restore
+func (h *Handler) postRestoreURLs(rw http.ResponseWriter, req *http.Request) {
+	var shortURLs []string
+	if err := json.NewDecoder(req.Body).Decode(&shortURLs); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.shortener.RestoreShortURLs(h.getUserID(req), shortURLs); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// PATCH /api/user/urls/{shortURL}
+func (h *Handler) patchUserURL(rw http.ResponseWriter, req *http.Request) {
+	var patch model.LinkPatch
+	if err := json.NewDecoder(req.Body).Decode(&patch); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	shortURL := chi.URLParam(req, "shortURL")
+	link, err := h.shortener.UpdateLink(h.getUserID(req), shortURL, patch)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		http.Error(rw, err.Error(), userLinkErrorStatus(err))
+		return
+	}
+
+	h.writeJSON(rw, link)
+}
+
+// GET /api/user/urls/{shortURL}/history
+func (h *Handler) getUserURLHistory(rw http.ResponseWriter, req *http.Request) {
+	shortURL := chi.URLParam(req, "shortURL")
+	history, err := h.shortener.GetLinkHistory(h.getUserID(req), shortURL)
+	if err != nil {
+		http.Error(rw, err.Error(), userLinkErrorStatus(err))
+		return
+	}
+
+	h.writeJSON(rw, history)
+}
+
+// POST /api/user/urls/{shortURL}/rollback
+func (h *Handler) postUserURLRollback(rw http.ResponseWriter, req *http.Request) {
+	type Request struct {
+		Version int `json:"version"`
+	}
+
+	var request Request
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
 		return
 	}
+
+	shortURL := chi.URLParam(req, "shortURL")
+	link, err := h.shortener.RollbackLink(h.getUserID(req), shortURL, request.Version)
+	if err != nil {
+		http.Error(rw, err.Error(), userLinkErrorStatus(err))
+		return
+	}
+
+	h.writeJSON(rw, link)
 }
 
 // DELETE /api/user/urls
@@ -286,6 +500,62 @@ func (h *Handler) shortenBatch(req *http.Request, rw http.ResponseWriter, origin
 	return links, nil
 }
 
+func (h *Handler) writeJSON(rw http.ResponseWriter, value interface{}) {
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	enc := json.NewEncoder(rw)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// userLinkErrorStatus Возвращает HTTP статус для ошибки операции над ссылкой пользователя.
+func userLinkErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, model.ErrLinkNotFound), errors.Is(err, model.ErrVersionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, model.ErrLinkShared):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func parseUserLinksQuery(values url.Values) (model.UserLinksQuery, error) {
+	query := model.UserLinksQuery{
+		Domain:   values.Get("domain"),
+		Contains: values.Get("q"),
+		Tag:      values.Get("tag"),
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return model.UserLinksQuery{}, fmt.Errorf("invalid limit: %q", limit)
+		}
+		query.Limit = n
+	}
+
+	switch order := values.Get("order"); order {
+	case "", "asc":
+		query.Order = model.SortAsc
+	case "desc":
+		query.Order = model.SortDesc
+	default:
+		return model.UserLinksQuery{}, fmt.Errorf("invalid order: %q", order)
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		after, err := model.DecodeCursor(cursor)
+		if err != nil {
+			return model.UserLinksQuery{}, err
+		}
+		query.After = &after
+	}
+
+	return query, nil
+}
+
 func decompressHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		var bodyDecompressor io.ReadCloser
@@ -326,6 +596,29 @@ func (h *Handler) getUserID(req *http.Request) model.UserID {
 	return model.UserID(id)
 }
 
+// getVisitorID Возвращает идентификатор посетителя из cookie. Если cookie нет или ее подпись неверна,
+// возвращает новый случайный идентификатор и false.
+func (h *Handler) getVisitorID(req *http.Request) (uint32, bool) {
+	id, ok := NewSignedCookie(h.CipherKey).GetInt(req, "visitor_id")
+	if ok && id >= 0 && id <= math.MaxInt32 {
+		return uint32(id), true
+	}
+
+	var buf [4]byte
+	_, _ = rand.Read(buf[:])
+	return binary.BigEndian.Uint32(buf[:]) & math.MaxInt32, false
+}
+
+// remoteIP Возвращает адрес клиента. middleware.RealIP заменяет RemoteAddr адресом из X-Real-IP
+// или X-Forwarded-For, в котором нет порта.
+func remoteIP(req *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
 func (h *Handler) setUserID(rw http.ResponseWriter, id model.UserID) {
 	if id != model.InvalidUserID {
 		NewSignedCookie(h.CipherKey).SetInt(rw, "user_id", int(id))
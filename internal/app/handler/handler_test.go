@@ -0,0 +1,393 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+	"github.com/ikashurnikov/shortener/internal/app/repo"
+	"github.com/ikashurnikov/shortener/internal/app/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHandler(t *testing.T) (*Handler, service.Shortener) {
+	baseURL, err := url.Parse("http://short.example")
+	require.NoError(t, err)
+	shortener := service.NewShortener(repo.NewInMemoryRepo(), *baseURL)
+	return NewHandler(shortener, "secret", ""), shortener
+}
+
+// createCode Сокращает ссылку от имени нового пользователя и возвращает пользователя и код ссылки.
+func createCode(t *testing.T, shortener service.Shortener, originalURL string) (model.UserID, string) {
+	userID := model.UserID(model.InvalidUserID)
+	link, err := shortener.CreateLink(&userID, originalURL)
+	require.NoError(t, err)
+	return userID, strings.TrimPrefix(link.ShortURL, "http://short.example/")
+}
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_HeadShortLink(t *testing.T) {
+	h, shortener := newTestHandler(t)
+
+	userID := model.UserID(model.InvalidUserID)
+	link, err := shortener.CreateLink(&userID, "https://example.com/page")
+	require.NoError(t, err)
+	code := strings.TrimPrefix(link.ShortURL, "http://short.example/")
+
+	get := serve(h, httptest.NewRequest(http.MethodGet, "/"+code, nil))
+	head := serve(h, httptest.NewRequest(http.MethodHead, "/"+code, nil))
+
+	assert.Equal(t, http.StatusTemporaryRedirect, get.Code)
+	assert.Equal(t, get.Code, head.Code)
+	assert.Equal(t, "https://example.com/page", head.Header().Get("Location"))
+	assert.Equal(t, get.Header().Get("Location"), head.Header().Get("Location"))
+	assert.Equal(t, get.Header().Get("Cache-Control"), head.Header().Get("Cache-Control"))
+	assert.Empty(t, head.Body.Bytes())
+
+	missingCode, err := service.NewZBase32LinkIDEncoder().EncodeToString(1)
+	require.NoError(t, err)
+	missingGet := serve(h, httptest.NewRequest(http.MethodGet, "/"+missingCode, nil))
+	missingHead := serve(h, httptest.NewRequest(http.MethodHead, "/"+missingCode, nil))
+	assert.NotEqual(t, http.StatusTemporaryRedirect, missingHead.Code)
+	assert.Equal(t, missingGet.Code, missingHead.Code)
+
+	// Переход учитывается только для GET.
+	h.Shutdown()
+	got, err := shortener.GetLinkByShortURL(code, model.Visit{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), got.Clicks)
+}
+
+func TestHandler_CORS(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.CORSAllowedOrigins = []string{"https://app.example", "*"}
+	defer h.Shutdown()
+
+	// Предварительный запрос браузера.
+	req := httptest.NewRequest(http.MethodOptions, "/api/shorten", nil)
+	req.Header.Set("Origin", "https://other.example")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "content-type")
+	rec := serve(h, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "POST, OPTIONS", rec.Header().Get("Allow"))
+	assert.Equal(t, "POST, OPTIONS", rec.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "content-type", rec.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+	assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Contains(t, rec.Header().Values("Vary"), "Origin")
+
+	// Запросы с перечисленных источников выполняются с cookie.
+	req = httptest.NewRequest(http.MethodOptions, "/api/user/urls", nil)
+	req.Header.Set("Origin", "https://app.example")
+	rec = serve(h, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "GET, DELETE, OPTIONS", rec.Header().Get("Allow"))
+	assert.Equal(t, "https://app.example", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Methods"))
+
+	req = httptest.NewRequest(http.MethodOptions, "/api/unknown", nil)
+	assert.Equal(t, http.StatusNotFound, serve(h, req).Code)
+
+	// Заголовки CORS добавляются только к ответам /api/*.
+	req = httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("Origin", "https://app.example")
+	rec = serve(h, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, rec.Header().Values("Vary"))
+
+	req = httptest.NewRequest(http.MethodOptions, "/yyyyyyy", nil)
+	req.Header.Set("Origin", "https://app.example")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	rec = serve(h, req)
+	assert.NotEqual(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestHandler_VisitorCookie(t *testing.T) {
+	h, shortener := newTestHandler(t)
+	defer h.Shutdown()
+
+	userID, code := createCode(t, shortener, "https://landing.example/")
+	variants := model.Variants{
+		{Name: "a", OriginalURL: "https://landing.example/a", Weight: 1},
+		{Name: "b", OriginalURL: "https://landing.example/b", Weight: 1},
+	}
+	_, err := shortener.UpdateLink(userID, code, model.LinkPatch{Variants: &variants})
+	require.NoError(t, err)
+
+	// Проверка ссылки не запоминает посетителя.
+	head := serve(h, httptest.NewRequest(http.MethodHead, "/"+code, nil))
+	assert.Equal(t, http.StatusTemporaryRedirect, head.Code)
+	assert.Empty(t, head.Result().Cookies())
+
+	get := serve(h, httptest.NewRequest(http.MethodGet, "/"+code, nil))
+	assert.Equal(t, http.StatusTemporaryRedirect, get.Code)
+	names := make([]string, 0)
+	for _, cookie := range get.Result().Cookies() {
+		names = append(names, cookie.Name)
+	}
+	assert.Contains(t, names, "visitor_id")
+
+	// Посетитель с cookie попадает на тот же вариант, cookie не выставляется повторно.
+	req := httptest.NewRequest(http.MethodGet, "/"+code, nil)
+	for _, cookie := range get.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	again := serve(h, req)
+	assert.Equal(t, get.Header().Get("Location"), again.Header().Get("Location"))
+	assert.Empty(t, again.Result().Cookies())
+}
+
+func TestHandler_Preview(t *testing.T) {
+	h, shortener := newTestHandler(t)
+
+	userID, code := createCode(t, shortener, "https://docs.example/guide")
+	title := "Guide"
+	_, err := shortener.UpdateLink(userID, code, model.LinkPatch{Title: &title})
+	require.NoError(t, err)
+
+	for _, target := range []string{"/" + code + "+", "/" + code + "?preview"} {
+		rec := serve(h, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusOK, rec.Code, target)
+		assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"), target)
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"), target)
+		assert.Empty(t, rec.Header().Get("Location"), target)
+
+		body := rec.Body.String()
+		assert.Contains(t, body, "<title>Guide</title>", target)
+		assert.Contains(t, body, "http://short.example/"+code, target)
+		assert.Contains(t, body, "https://docs.example/guide", target)
+		assert.NotContains(t, body, `id="countdown"`, target)
+	}
+
+	// Предпросмотр не считается переходом.
+	h.Shutdown()
+	got, err := shortener.GetLinkByShortURL(code, model.Visit{})
+	require.NoError(t, err)
+	assert.Zero(t, got.Clicks)
+}
+
+func TestHandler_Interstitial(t *testing.T) {
+	h, shortener := newTestHandler(t)
+
+	userID, code := createCode(t, shortener, "https://untrusted.example/download")
+	interstitial := true
+	_, err := shortener.UpdateLink(userID, code, model.LinkPatch{Interstitial: &interstitial})
+	require.NoError(t, err)
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/"+code, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Empty(t, rec.Header().Get("Location"))
+	assert.Contains(t, rec.Body.String(), `id="countdown"`)
+	assert.Contains(t, rec.Body.String(), `https://untrusted.example/download`)
+
+	// Страница промежуточного перехода, в отличие от предпросмотра, считается переходом.
+	h.Shutdown()
+	got, err := shortener.GetLinkByShortURL(code, model.Visit{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), got.Clicks)
+}
+
+func TestHandler_QRCode(t *testing.T) {
+	h, shortener := newTestHandler(t)
+	defer h.Shutdown()
+
+	userID, code := createCode(t, shortener, "https://docs.example/qr")
+
+	png := serve(h, httptest.NewRequest(http.MethodGet, "/"+code+"/qr.png?size=64", nil))
+	require.Equal(t, http.StatusOK, png.Code)
+	assert.Equal(t, "image/png", png.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=86400", png.Header().Get("Cache-Control"))
+	img, _, err := image.DecodeConfig(bytes.NewReader(png.Body.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, 64, img.Width)
+
+	svg := serve(h, httptest.NewRequest(http.MethodGet, "/"+code+"/qr.svg?fg=000&bg=FFFFFF", nil))
+	require.Equal(t, http.StatusOK, svg.Code)
+	assert.Equal(t, "image/svg+xml", svg.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=86400", svg.Header().Get("Cache-Control"))
+	assert.Contains(t, svg.Body.String(), "<svg")
+
+	// Повторный запрос с теми же параметрами отдается из кеша и совпадает с первым.
+	cached := serve(h, httptest.NewRequest(http.MethodGet, "/"+code+"/qr.png?size=64", nil))
+	assert.Equal(t, png.Body.Bytes(), cached.Body.Bytes())
+
+	// Размер ограничен, чтобы публичный метод не рисовал изображения произвольного размера.
+	large := serve(h, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/%s/qr.png?size=%d", code, model.MaxQRCodeSize), nil))
+	require.Equal(t, http.StatusOK, large.Code)
+	img, _, err = image.DecodeConfig(bytes.NewReader(large.Body.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, model.MaxQRCodeSize, img.Width)
+	for _, query := range []string{
+		fmt.Sprintf("size=%d", model.MinQRCodeSize-1),
+		fmt.Sprintf("size=%d", model.MaxQRCodeSize+1),
+		"size=abc",
+		"level=X",
+		"fg=red",
+	} {
+		target := "/" + code + "/qr.svg?" + query
+		rec := serve(h, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+		assert.Empty(t, rec.Header().Get("Cache-Control"), target)
+	}
+
+	// Для удаленной ссылки код не выдается и не кешируется.
+	require.NoError(t, shortener.DeleteShortURLs(userID, []string{code}))
+	removed := serve(h, httptest.NewRequest(http.MethodGet, "/"+code+"/qr.png?size=64", nil))
+	assert.Equal(t, http.StatusGone, removed.Code)
+	assert.Empty(t, removed.Header().Get("Cache-Control"))
+}
+
+func TestHandler_NonCanonicalCode(t *testing.T) {
+	h, shortener := newTestHandler(t)
+
+	_, code := createCode(t, shortener, "https://docs.example/canonical")
+	upper := strings.ToUpper(code)
+	require.NotEqual(t, code, upper)
+
+	for target, location := range map[string]string{
+		"/" + upper:                  "http://short.example/" + code,
+		"/" + upper + "+":            "http://short.example/" + code + "+",
+		"/" + upper + "?utm=x&y=1":   "http://short.example/" + code + "?utm=x&y=1",
+		"/" + upper + "+?preview=on": "http://short.example/" + code + "+?preview=on",
+	} {
+		for _, method := range []string{http.MethodGet, http.MethodHead} {
+			rec := serve(h, httptest.NewRequest(method, target, nil))
+			assert.Equal(t, http.StatusMovedPermanently, rec.Code, method, target)
+			assert.Equal(t, location, rec.Header().Get("Location"), method, target)
+		}
+	}
+
+	// Перенаправление на канонический код не считается переходом.
+	h.Shutdown()
+	got, err := shortener.GetLinkByShortURL(code, model.Visit{})
+	require.NoError(t, err)
+	assert.Zero(t, got.Clicks)
+}
+
+func TestHandler_BlockedDestination(t *testing.T) {
+	hostsFile := filepath.Join(t.TempDir(), "hosts.txt")
+	require.NoError(t, os.WriteFile(hostsFile, nil, 0664))
+	blocklist, err := service.NewBlocklist(hostsFile, "", model.DefaultURLCanonicalization)
+	require.NoError(t, err)
+
+	baseURL, err := url.Parse("http://short.example")
+	require.NoError(t, err)
+	shortener := service.NewShortener(repo.NewInMemoryRepo(), *baseURL, service.WithBlocklist(blocklist))
+	h := NewHandler(shortener, "secret", "")
+
+	_, code := createCode(t, shortener, "https://phish.example/login?next=<script>")
+
+	// Ссылка, сохраненная до попадания в список, перестает перенаправлять.
+	require.NoError(t, os.WriteFile(hostsFile, []byte("phish.example\n"), 0664))
+	_, err = blocklist.Reload()
+	require.NoError(t, err)
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		rec := serve(h, httptest.NewRequest(method, "/"+code, nil))
+		assert.Equal(t, http.StatusForbidden, rec.Code, method)
+		assert.Empty(t, rec.Header().Get("Location"), method)
+		assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"), method)
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"), method)
+	}
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/"+code, nil))
+	body := rec.Body.String()
+	assert.Contains(t, body, "Warning: this link has been disabled")
+	assert.Contains(t, body, "http://short.example/"+code)
+	// Адрес выводится экранированным текстом, а не ссылкой.
+	assert.Contains(t, body, "phish.example/login?next=&lt;script&gt;")
+	assert.NotContains(t, body, "<script>")
+	assert.NotContains(t, body, "href=")
+
+	// Предпросмотр тоже не показывает заблокированный адрес ссылкой.
+	rec = serve(h, httptest.NewRequest(http.MethodGet, "/"+code+"+", nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "href=")
+
+	// Переходы по заблокированной ссылке не учитываются.
+	h.Shutdown()
+	require.NoError(t, os.WriteFile(hostsFile, nil, 0664))
+	_, err = blocklist.Reload()
+	require.NoError(t, err)
+	got, err := shortener.GetLinkByShortURL(code, model.Visit{})
+	require.NoError(t, err)
+	assert.Zero(t, got.Clicks)
+}
+
+func TestHandler_RedirectCacheControl(t *testing.T) {
+	h, shortener := newTestHandler(t)
+	defer h.Shutdown()
+
+	const noStore = "private, no-cache, no-store, max-age=0"
+	public := fmt.Sprintf("public, max-age=%d", int64(DefaultPermanentRedirectMaxAge.Seconds()))
+
+	statuses := map[int]string{
+		http.StatusMovedPermanently:  public,
+		http.StatusPermanentRedirect: public,
+		http.StatusFound:             noStore,
+		http.StatusTemporaryRedirect: noStore,
+	}
+	for status, cacheControl := range statuses {
+		userID, code := createCode(t, shortener, fmt.Sprintf("https://docs.example/%d", status))
+		status := status
+		_, err := shortener.UpdateLink(userID, code, model.LinkPatch{RedirectStatus: &status})
+		require.NoError(t, err)
+
+		rec := serve(h, httptest.NewRequest(http.MethodGet, "/"+code, nil))
+		assert.Equal(t, status, rec.Code)
+		assert.Equal(t, cacheControl, rec.Header().Get("Cache-Control"), status)
+		assert.NotEmpty(t, rec.Header().Get("Expires"), status)
+	}
+
+	// Постоянные перенаправления с правилами перехода или вариантами зависят от посетителя и не кешируются.
+	permanent := http.StatusMovedPermanently
+	routing := model.RoutingRules{{Platforms: []model.Platform{model.PlatformIOS}, OriginalURL: "https://apps.apple.com/app/id1"}}
+	variants := model.Variants{
+		{Name: "a", OriginalURL: "https://landing.example/a", Weight: 1},
+		{Name: "b", OriginalURL: "https://landing.example/b", Weight: 1},
+	}
+	for name, patch := range map[string]model.LinkPatch{
+		"routing":  {RedirectStatus: &permanent, Routing: &routing},
+		"variants": {RedirectStatus: &permanent, Variants: &variants},
+	} {
+		userID, code := createCode(t, shortener, "https://landing.example/"+name)
+		_, err := shortener.UpdateLink(userID, code, patch)
+		require.NoError(t, err)
+
+		rec := serve(h, httptest.NewRequest(http.MethodGet, "/"+code, nil))
+		assert.Equal(t, http.StatusMovedPermanently, rec.Code, name)
+		assert.Equal(t, noStore, rec.Header().Get("Cache-Control"), name)
+	}
+
+	// Нулевое время кеширования запрещает кеширование и постоянных перенаправлений.
+	h.PermanentRedirectMaxAge = 0
+	userID, code := createCode(t, shortener, "https://docs.example/no-cache")
+	_, err := shortener.UpdateLink(userID, code, model.LinkPatch{RedirectStatus: &permanent})
+	require.NoError(t, err)
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/"+code, nil))
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, noStore, rec.Header().Get("Cache-Control"))
+}
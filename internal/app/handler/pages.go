@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"embed"
+	"html/template"
+	"net/http"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+)
+
+// interstitialCountdown Время в секундах до перехода со страницы предпросмотра ссылки
+// с включенным Interstitial.
+const interstitialCountdown = 5
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var pages = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// writeWarningPage Отвечает страницей предупреждения о ссылке на фишинговый или вредоносный адрес.
+// Адрес выводится текстом, а не ссылкой, чтобы по нему нельзя было перейти случайно.
+func writeWarningPage(rw http.ResponseWriter, shortURL string, originalURL string) {
+	data := struct{ ShortURL, OriginalURL string }{shortURL, originalURL}
+	writePage(rw, http.StatusForbidden, "warning.html", data)
+}
+
+// writePreviewPage Отвечает страницей предпросмотра ссылки. Если countdown больше 0,
+// страница перенаправляет на оригинальную ссылку через countdown секунд.
+func writePreviewPage(rw http.ResponseWriter, link model.Link, countdown int) {
+	data := struct {
+		Link      model.Link
+		Countdown int
+	}{link, countdown}
+	writePage(rw, http.StatusOK, "preview.html", data)
+}
+
+func writePage(rw http.ResponseWriter, status int, name string, data interface{}) {
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(status)
+	_ = pages.ExecuteTemplate(rw, name, data)
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ikashurnikov/shortener/internal/app/model"
+)
+
+// qrCodeMaxAge Время кеширования изображения QR-кода клиентом, в секундах.
+// Код содержит только короткую ссылку и не меняется вместе с оригинальной ссылкой.
+const qrCodeMaxAge = 24 * 60 * 60
+
+// GET /{shortURL}/qr.png
+func (h *Handler) getQRCodePNG(rw http.ResponseWriter, req *http.Request) {
+	h.writeQRCode(rw, req, model.QRCodePNG, "image/png")
+}
+
+// GET /{shortURL}/qr.svg
+func (h *Handler) getQRCodeSVG(rw http.ResponseWriter, req *http.Request) {
+	h.writeQRCode(rw, req, model.QRCodeSVG, "image/svg+xml")
+}
+
+func (h *Handler) writeQRCode(rw http.ResponseWriter, req *http.Request, format model.QRCodeFormat, contentType string) {
+	opts, err := parseQRCodeOptions(format, req.URL.Query())
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	image, err := h.shortener.GetQRCode(chi.URLParam(req, "shortURL"), opts)
+	if err != nil {
+		status := userLinkErrorStatus(err)
+		if errors.Is(err, model.ErrLinkRemoved) || errors.Is(err, model.ErrLinkDisabled) {
+			status = http.StatusGone
+		}
+		http.Error(rw, err.Error(), status)
+		return
+	}
+
+	rw.Header().Set("Content-Type", contentType)
+	rw.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", qrCodeMaxAge))
+	rw.Write(image)
+}
+
+// parseQRCodeOptions Разбирает параметры QR-кода: size (пиксели), level (L, M, Q, H),
+// margin (модули), fg и bg (цвета в записи RRGGBB или RGB).
+func parseQRCodeOptions(format model.QRCodeFormat, values url.Values) (model.QRCodeOptions, error) {
+	opts := model.DefaultQRCodeOptions(format)
+
+	for name, value := range map[string]*int{"size": &opts.Size, "margin": &opts.Margin} {
+		if s := values.Get(name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return model.QRCodeOptions{}, fmt.Errorf("invalid %s: %q", name, s)
+			}
+			*value = n
+		}
+	}
+
+	if level := values.Get("level"); level != "" {
+		opts.Level = model.QRCodeLevel(strings.ToUpper(level))
+	}
+
+	for name, value := range map[string]*color.RGBA{"fg": &opts.Foreground, "bg": &opts.Background} {
+		if s := values.Get(name); s != "" {
+			c, err := model.ParseHexColor(s)
+			if err != nil {
+				return model.QRCodeOptions{}, fmt.Errorf("invalid %s: %q", name, s)
+			}
+			*value = c
+		}
+	}
+
+	if err := opts.Validate(); err != nil {
+		return model.QRCodeOptions{}, err
+	}
+	return opts, nil
+}
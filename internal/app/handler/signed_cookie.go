@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type SignedCookie struct {
@@ -47,18 +48,29 @@ func (c SignedCookie) GetInt(req *http.Request, name string) (int, bool) {
 }
 
 func (c SignedCookie) Set(rw http.ResponseWriter, name, value string) {
+	c.SetWithMaxAge(rw, name, value, 0)
+}
+
+func (c SignedCookie) SetInt(rw http.ResponseWriter, name string, value int) {
+	c.Set(rw, name, strconv.Itoa(value))
+}
+
+// SetWithMaxAge Устанавливает cookie, которая хранится maxAge. 0 - до закрытия браузера.
+func (c SignedCookie) SetWithMaxAge(rw http.ResponseWriter, name, value string, maxAge time.Duration) {
 	http.SetCookie(rw, &http.Cookie{
-		Name:  name,
-		Value: value,
+		Name:   name,
+		Value:  value,
+		MaxAge: int(maxAge.Seconds()),
 	})
 	http.SetCookie(rw, &http.Cookie{
-		Name:  signedCookieName(name),
-		Value: c.sign(value),
+		Name:   signedCookieName(name),
+		Value:  c.sign(value),
+		MaxAge: int(maxAge.Seconds()),
 	})
 }
 
-func (c SignedCookie) SetInt(rw http.ResponseWriter, name string, value int) {
-	c.Set(rw, name, strconv.Itoa(value))
+func (c SignedCookie) SetIntWithMaxAge(rw http.ResponseWriter, name string, value int, maxAge time.Duration) {
+	c.SetWithMaxAge(rw, name, strconv.Itoa(value), maxAge)
 }
 
 func (c *SignedCookie) Add(req *http.Request, name, value string) {
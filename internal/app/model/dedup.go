@@ -0,0 +1,60 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// DedupMode Режим дедупликации оригинальных ссылок.
+//
+// Режим определяет, когда сохранение ссылки возвращает ErrLinkAlreadyExists:
+//   - DedupGlobal  - ссылка уже была сокращена любым пользователем;
+//   - DedupPerUser - ссылка уже была сокращена этим же пользователем;
+//   - DedupNone    - никогда, каждое сокращение создает новую короткую ссылку.
+type DedupMode int
+
+const (
+	// DedupGlobal Оригинальная ссылка всегда получает один и тот же короткий код.
+	DedupGlobal DedupMode = iota
+	// DedupPerUser Каждый пользователь получает собственный короткий код для оригинальной ссылки.
+	DedupPerUser
+	// DedupNone Каждое сокращение создает новый короткий код.
+	DedupNone
+)
+
+var dedupModeNames = map[DedupMode]string{
+	DedupGlobal:  "global",
+	DedupPerUser: "per-user",
+	DedupNone:    "none",
+}
+
+// DedupKey Возвращает ключ дедупликации оригинальной ссылки.
+// Пустая строка означает, что ссылка не дедуплицируется.
+func (m DedupMode) DedupKey(userID UserID, originalURL string) string {
+	switch m {
+	case DedupPerUser:
+		// Нормализованная ссылка не может начинаться с цифры, поэтому ключи не пересекаются с DedupGlobal.
+		return strconv.Itoa(int(userID)) + ":" + originalURL
+	case DedupNone:
+		return ""
+	default:
+		return originalURL
+	}
+}
+
+func (m DedupMode) String() string {
+	if name, ok := dedupModeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("DedupMode(%d)", int(m))
+}
+
+func (m *DedupMode) UnmarshalText(text []byte) error {
+	for mode, name := range dedupModeNames {
+		if name == string(text) {
+			*m = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown dedup mode: %q", text)
+}
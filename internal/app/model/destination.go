@@ -0,0 +1,181 @@
+package model
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DestinationPolicy Ограничения на оригинальные ссылки, по которым сервис перенаправляет пользователей.
+//
+// Без ограничений короткая ссылка может вести на javascript:, data: или file:, а также
+// на адреса внутренней сети, что открывает путь к XSS и SSRF.
+type DestinationPolicy struct {
+	// AllowedSchemes Разрешенные схемы. Пустой список разрешает любую схему.
+	AllowedSchemes []string
+	// RequireHost Запрещает ссылки без хоста, например /foo или mailto:.
+	RequireHost bool
+	// AllowPrivateIPs Разрешает ссылки на локальные и частные адреса (127.0.0.1, 10.0.0.0/8, localhost).
+	AllowPrivateIPs bool
+	// AllowedDomains Домены, на которые разрешены ссылки, вместе с поддоменами.
+	// Пустой список разрешает любой домен, кроме DeniedDomains.
+	AllowedDomains []string
+	// DeniedDomains Домены, на которые запрещены ссылки, вместе с поддоменами.
+	DeniedDomains []string
+	// LookupIP Разрешает имя хоста в адреса, чтобы проверить их при запрещенных частных адресах.
+	// Если не задан, проверяются только адреса, записанные в ссылке явно.
+	LookupIP func(host string) ([]net.IP, error)
+}
+
+// DefaultDestinationPolicy Возвращает политику, разрешающую только ссылки http и https на публичные хосты.
+func DefaultDestinationPolicy() DestinationPolicy {
+	return DestinationPolicy{
+		AllowedSchemes: []string{"http", "https"},
+		RequireHost:    true,
+	}
+}
+
+// cgnat Разделяемое адресное пространство операторов (RFC 6598), не маршрутизируемое в интернете.
+var cgnat = net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// Check Проверяет оригинальную ссылку. Возвращает ErrForbiddenDestination, если ссылка запрещена.
+func (p *DestinationPolicy) Check(originalURL string) error {
+	u, err := url.Parse(originalURL)
+	if err != nil {
+		return ErrInvalidURL
+	}
+
+	if len(p.AllowedSchemes) > 0 {
+		allowed := false
+		for _, scheme := range p.AllowedSchemes {
+			allowed = allowed || strings.EqualFold(scheme, u.Scheme)
+		}
+		if !allowed {
+			return ErrForbiddenDestination
+		}
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		if p.RequireHost {
+			return ErrForbiddenDestination
+		}
+		return nil
+	}
+
+	for _, domain := range p.DeniedDomains {
+		if matchHost(host, domain) {
+			return ErrForbiddenDestination
+		}
+	}
+
+	if len(p.AllowedDomains) > 0 {
+		allowed := false
+		for _, domain := range p.AllowedDomains {
+			allowed = allowed || matchHost(host, domain)
+		}
+		if !allowed {
+			return ErrForbiddenDestination
+		}
+	}
+
+	if !p.AllowPrivateIPs {
+		return p.checkPublicHost(host)
+	}
+	return nil
+}
+
+// checkPublicHost Проверяет, что хост не указывает на локальный или частный адрес.
+func (p *DestinationPolicy) checkPublicHost(host string) error {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrForbiddenDestination
+	}
+
+	if ip, isIP, ok := parseHostIP(host); isIP {
+		if !ok || !isPublicIP(ip) {
+			return ErrForbiddenDestination
+		}
+		return nil
+	}
+
+	if p.LookupIP == nil {
+		return nil
+	}
+
+	ips, err := p.LookupIP(host)
+	if err != nil {
+		return ErrForbiddenDestination
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return ErrForbiddenDestination
+		}
+	}
+	return nil
+}
+
+// parseHostIP Разбирает хост, записанный IP-адресом. Как и браузеры, понимает IPv4 в сокращенной,
+// восьмеричной и шестнадцатеричной записи (127.1, 0x7f.0.0.1, 2130706433).
+// isIP - хост записан адресом, ok - адрес записан верно.
+func parseHostIP(host string) (ip net.IP, isIP bool, ok bool) {
+	if strings.Contains(host, ":") {
+		// IPv6, возможно с зоной: fe80::1%eth0.
+		host, _, _ = strings.Cut(host, "%")
+		ip = net.ParseIP(host)
+		return ip, true, ip != nil
+	}
+
+	if ip = net.ParseIP(host); ip != nil {
+		return ip, true, true
+	}
+
+	labels := strings.Split(host, ".")
+	if _, err := parseIPv4Part(labels[len(labels)-1]); err != nil {
+		return nil, false, false
+	}
+	if len(labels) > 4 {
+		return nil, true, false
+	}
+
+	var value uint64
+	for i, label := range labels {
+		part, err := parseIPv4Part(label)
+		if err != nil {
+			return nil, true, false
+		}
+
+		if i < len(labels)-1 {
+			if part > 0xff {
+				return nil, true, false
+			}
+			value |= part << (8 * uint(3-i))
+			continue
+		}
+
+		if part >= 1<<(8*uint(5-len(labels))) {
+			return nil, true, false
+		}
+		value |= part
+	}
+	return net.IPv4(byte(value>>24), byte(value>>16), byte(value>>8), byte(value)), true, true
+}
+
+func parseIPv4Part(label string) (uint64, error) {
+	base := 10
+	switch {
+	case strings.HasPrefix(label, "0x"):
+		label, base = label[2:], 16
+		if label == "" {
+			return 0, nil
+		}
+	case len(label) > 1 && label[0] == '0':
+		label, base = label[1:], 8
+	}
+	return strconv.ParseUint(label, base, 32)
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || cgnat.Contains(ip))
+}
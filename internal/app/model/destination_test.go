@@ -0,0 +1,99 @@
+package model
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDestinationPolicy_Check(t *testing.T) {
+	policy := DefaultDestinationPolicy()
+	policy.DeniedDomains = []string{"evil.com"}
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{url: "https://yandex.ru/search?q=1", allowed: true},
+		{url: "HTTP://Example.com", allowed: true},
+		{url: "http://93.184.216.34/", allowed: true},
+		{url: "http://[2606:2800:220:1:248:1893:25c8:1946]/", allowed: true},
+		{url: "javascript:alert(1)", allowed: false},
+		{url: "data:text/html,<script>", allowed: false},
+		{url: "file:///etc/passwd", allowed: false},
+		{url: "/foo", allowed: false},
+		{url: "http:///foo", allowed: false},
+		{url: "http://localhost:8080/", allowed: false},
+		{url: "http://api.localhost/", allowed: false},
+		{url: "http://127.0.0.1/", allowed: false},
+		{url: "http://127.1/", allowed: false},
+		{url: "http://2130706433/", allowed: false},
+		{url: "http://0x7f.0.0.1/", allowed: false},
+		{url: "http://0177.0.0.1/", allowed: false},
+		{url: "http://10.0.0.1/", allowed: false},
+		{url: "http://192.168.1.1/", allowed: false},
+		{url: "http://169.254.169.254/latest/meta-data", allowed: false},
+		{url: "http://100.64.0.1/", allowed: false},
+		{url: "http://0.0.0.0/", allowed: false},
+		{url: "http://[::1]/", allowed: false},
+		{url: "http://[::ffff:127.0.0.1]/", allowed: false},
+		{url: "http://[fe80::1%25eth0]/", allowed: false},
+		{url: "http://1.2.3.4.5/", allowed: false},
+		{url: "http://example.256/", allowed: false},
+		{url: "https://evil.com/", allowed: false},
+		{url: "https://www.evil.com/", allowed: false},
+		{url: "https://evil.com./", allowed: false},
+		{url: "https://www.evil.com./x", allowed: false},
+		{url: "https://EVIL.com.:443/", allowed: false},
+		{url: "https://notevil.com/", allowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := policy.Check(tt.url)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrForbiddenDestination)
+			}
+		})
+	}
+}
+
+func TestDestinationPolicy_AllowedDomains(t *testing.T) {
+	policy := DefaultDestinationPolicy()
+	policy.AllowedDomains = []string{"yandex.ru"}
+
+	assert.NoError(t, policy.Check("https://market.yandex.ru/"))
+	assert.NoError(t, policy.Check("https://yandex.ru./"))
+	assert.ErrorIs(t, policy.Check("https://google.com/"), ErrForbiddenDestination)
+}
+
+func TestDestinationPolicy_LookupIP(t *testing.T) {
+	policy := DefaultDestinationPolicy()
+	policy.LookupIP = func(host string) ([]net.IP, error) {
+		switch host {
+		case "internal.example.com":
+			return []net.IP{net.ParseIP("93.184.216.34"), net.ParseIP("10.1.2.3")}, nil
+		case "public.example.com":
+			return []net.IP{net.ParseIP("93.184.216.34")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	assert.NoError(t, policy.Check("https://public.example.com/"))
+	assert.ErrorIs(t, policy.Check("https://internal.example.com/"), ErrForbiddenDestination)
+	assert.ErrorIs(t, policy.Check("https://unknown.example.com/"), ErrForbiddenDestination)
+
+	policy.AllowPrivateIPs = true
+	assert.NoError(t, policy.Check("https://internal.example.com/"))
+	assert.NoError(t, policy.Check("http://127.0.0.1/"))
+}
+
+func TestDestinationPolicy_AnyScheme(t *testing.T) {
+	policy := DestinationPolicy{AllowPrivateIPs: true}
+	assert.NoError(t, policy.Check("mailto:user@example.com"))
+	assert.NoError(t, policy.Check("/foo"))
+}
@@ -3,13 +3,35 @@ package model
 import "errors"
 
 var (
-	ErrInvalidUserID       = errors.New("invalid user id")
-	ErrUserNotFound        = errors.New("user not found")
-	ErrLinkNotFound        = errors.New("link not found")
-	ErrLinkAlreadyExists   = errors.New("link already exists")
-	ErrInvalidURL          = errors.New("invalid url")
-	ErrInternalError       = errors.New("internal error")
-	ErrEncodingOriginalURL = errors.New("encoding original url failed")
-	ErrDecodingShortURL    = errors.New("decoding short url failed")
-	ErrLinkRemoved         = errors.New("link has been removed")
+	ErrInvalidUserID        = errors.New("invalid user id")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrLinkNotFound         = errors.New("link not found")
+	ErrLinkAlreadyExists    = errors.New("link already exists")
+	ErrInvalidURL           = errors.New("invalid url")
+	ErrInternalError        = errors.New("internal error")
+	ErrEncodingOriginalURL  = errors.New("encoding original url failed")
+	ErrDecodingShortURL     = errors.New("decoding short url failed")
+	ErrInvalidChecksum      = errors.New("short url checksum mismatch")
+	ErrLinkRemoved          = errors.New("link has been removed")
+	ErrInvalidCursor        = errors.New("invalid cursor")
+	ErrInvalidLinkPatch     = errors.New("invalid link patch")
+	ErrLinkShared           = errors.New("link is shared with other users")
+	ErrVersionNotFound      = errors.New("link version not found")
+	ErrInvalidAlphabet      = errors.New("invalid alphabet")
+	ErrInvalidSecret        = errors.New("invalid secret")
+	ErrBlockedWord          = errors.New("short url contains a blocked word")
+	ErrNoFreeLinkID         = errors.New("no free link id")
+	ErrNonCanonicalCode     = errors.New("short url code is not canonical")
+	ErrInvalidRewriteRule   = errors.New("invalid rewrite rule")
+	ErrForbiddenDestination = errors.New("destination is not allowed")
+	ErrSelfLink             = errors.New("destination is a link to this service")
+	ErrRedirectLoop         = errors.New("destination redirects in a loop")
+	ErrBlockedDestination   = errors.New("destination is blocklisted as phishing or malware")
+	ErrLinkDisabled         = errors.New("link has been disabled by a moderator")
+	ErrInvalidReport        = errors.New("invalid abuse report")
+	ErrInvalidQRCodeOptions = errors.New("invalid qr code options")
+	ErrInvalidRoutingRule   = errors.New("invalid routing rule")
+	ErrInvalidVariant       = errors.New("invalid link variant")
+	ErrUnknownLinkIDFormat  = errors.New("unknown link id format")
+	ErrAmbiguousUnchecked   = errors.New("unchecked codes have the same length as checked ones")
 )
@@ -1,25 +1,370 @@
 package model
 
 import (
+	"encoding/base64"
+	"math"
+	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/exp/slices"
 )
 
-type LinkID uint32
+type LinkID uint64
+
+// MaxLinkID Максимальный ID ссылки. ID должен помещаться в BIGINT, поэтому старший бит не используется.
+const MaxLinkID LinkID = math.MaxInt64
+
+// LegacyMaxLinkID Максимальный ID ссылки до перехода на 64-битные ID.
+const LegacyMaxLinkID LinkID = math.MaxUint32
 
 type Link struct {
-	ShortURL    string `json:"short_url"`
+	ShortURL    string    `json:"short_url"`
+	OriginalURL string    `json:"original_url"`
+	CreatedAt   time.Time `json:"created_at"`
+	Title       string    `json:"title,omitempty"`
+	Note        string    `json:"note,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	// DeletedAt Время удаления ссылки в корзину.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Clicks Кол-во переходов по ссылке всех пользователей.
+	Clicks int64 `json:"clicks"`
+	// Interstitial Перед переходом по ссылке всегда показывается страница предпросмотра с обратным отсчетом.
+	Interstitial bool `json:"interstitial,omitempty"`
+	// RedirectStatus HTTP статус перенаправления по ссылке. 0 в списке ссылок - статус по умолчанию.
+	RedirectStatus int `json:"redirect_status,omitempty"`
+	// Routing Правила выбора оригинальной ссылки по устройству и языку посетителя.
+	Routing RoutingRules `json:"routing,omitempty"`
+	// Variants Варианты оригинальной ссылки для A/B-теста с кол-вом переходов на каждый вариант.
+	Variants Variants `json:"variants,omitempty"`
+	// Variant Вариант, выбранный для посетителя при переходе по ссылке.
+	Variant string `json:"variant,omitempty"`
+	// Country Страна посетителя, определенная при переходе по ссылке.
+	Country string `json:"country,omitempty"`
+	// CountryClicks Кол-во переходов по ссылке по ISO-кодам стран посетителей.
+	CountryClicks map[string]int64 `json:"country_clicks,omitempty"`
+	// QRCodeURL Ссылка на изображение QR-кода короткой ссылки.
+	QRCodeURL string `json:"qr_code_url,omitempty"`
+}
+
+// UserLink Ссылка, сохраненная пользователем, и ее метаданные.
+type UserLink struct {
+	ID          LinkID
+	UserID      UserID
+	OriginalURL string
+	CreatedAt   time.Time
+	Title       string
+	Note        string
+	Tags        []string
+	// DeletedAt Время удаления ссылки в корзину. nil, если ссылка не удалена.
+	DeletedAt *time.Time
+	// Clicks, CountryClicks, Interstitial, RedirectStatus, Routing, Variants Общие для всех пользователей
+	// свойства ссылки (см. LinkDetails).
+	Clicks         int64
+	CountryClicks  map[string]int64
+	Interstitial   bool
+	RedirectStatus int
+	Routing        RoutingRules
+	Variants       Variants
+}
+
+// LinkDetails Общие для всех пользователей сведения о ссылке.
+type LinkDetails struct {
+	// Title, Note Заголовок и описание, заданные автором ссылки. Пустые, если у автора больше нет ссылки.
+	Title string
+	Note  string
+	// Clicks Кол-во переходов по ссылке.
+	Clicks int64
+	// CountryClicks Кол-во переходов по ISO-кодам стран. Переходы из неизвестных стран не учитываются.
+	CountryClicks map[string]int64
+	// Interstitial Перед переходом по ссылке всегда показывается страница предпросмотра.
+	Interstitial bool
+	// RedirectStatus HTTP статус перенаправления: 301, 302, 307 или 308. 0 - статус по умолчанию.
+	RedirectStatus int
+	// Routing Правила выбора оригинальной ссылки при переходе.
+	Routing RoutingRules
+	// Variants Варианты оригинальной ссылки, если ни одно правило Routing не выполнено.
+	Variants Variants
+}
+
+const (
+	MaxTitleLen = 256
+	MaxNoteLen  = 4096
+	MaxTagLen   = 64
+	MaxTagCount = 32
+)
+
+// LinkPatch Изменение метаданных ссылки пользователя. Поля, равные nil, не изменяются.
+type LinkPatch struct {
+	Title *string   `json:"title"`
+	Note  *string   `json:"note"`
+	Tags  *[]string `json:"tags"`
+	// OriginalURL Новая оригинальная ссылка. Изменяется хранилищем, как в Repo.RetargetLink, Apply поле игнорирует.
+	OriginalURL *string `json:"original_url"`
+	// Interstitial Показывать страницу предпросмотра перед переходом.
+	Interstitial *bool `json:"interstitial"`
+	// RedirectStatus HTTP статус перенаправления: 301, 302, 307 или 308, 0 - статус по умолчанию.
+	RedirectStatus *int `json:"redirect_status"`
+	// Routing Правила выбора оригинальной ссылки при переходе. Пустой список удаляет правила.
+	Routing *RoutingRules `json:"routing"`
+	// Variants Варианты оригинальной ссылки. Кол-во переходов сохраняется у вариантов с прежними именами,
+	// пустой список удаляет варианты.
+	Variants *Variants `json:"variants"`
+}
+
+// HasLinkSettings Возвращает true, если изменение затрагивает общие для всех пользователей настройки
+// ссылки (Interstitial, RedirectStatus, Routing, Variants). Они изменяются хранилищем, как в Repo.UpdateLinkSettings,
+// Apply их игнорирует.
+func (p *LinkPatch) HasLinkSettings() bool {
+	return p.Interstitial != nil || p.RedirectStatus != nil || p.Routing != nil || p.Variants != nil
+}
+
+// IsRedirectStatus Проверяет, что status - допустимый статус перенаправления по ссылке.
+func IsRedirectStatus(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// IsPermanentRedirect Проверяет, что статус перенаправления постоянный и переход может кешироваться.
+func IsPermanentRedirect(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
+}
+
+// LinkVersion Версия оригинальной ссылки.
+// Каждое изменение оригинальной ссылки сохраняется как новая версия, первая версия создается вместе со ссылкой.
+type LinkVersion struct {
+	Version     int       `json:"version"`
+	OriginalURL string    `json:"original_url"`
+	CreatedAt   time.Time `json:"created_at"`
+	// Author Пользователь, создавший версию. InvalidUserID, если неизвестен.
+	Author UserID `json:"author"`
+}
+
+// BlockedLink Ссылка, оригинальная ссылка которой попала в список фишинговых и вредоносных адресов.
+type BlockedLink struct {
+	ShortURL string `json:"short_url"`
+	// OriginalURL Заблокированная ссылка: оригинальная ссылка, ссылка правила перехода или варианта.
 	OriginalURL string `json:"original_url"`
+	// Rule Хост или префикс ссылки из списка, которому соответствует оригинальная ссылка.
+	Rule string `json:"rule"`
+}
+
+// SortOrder Порядок сортировки ссылок пользователя по ID ссылки. ID выдаются в порядке создания ссылок,
+// поэтому ссылка, ранее сокращенная другим пользователем, стоит на месте своего ID,
+// а не времени добавления в список пользователя.
+type SortOrder int
+
+const (
+	// SortAsc По возрастанию ID.
+	SortAsc SortOrder = iota
+	// SortDesc По убыванию ID.
+	SortDesc
+)
+
+// UserLinksQuery Параметры постраничной выборки ссылок пользователя.
+type UserLinksQuery struct {
+	// After Курсор. Если задан, выбираются только ссылки, следующие (в порядке Order) за ссылкой с этим ID.
+	After *LinkID
+	// Limit Максимальное кол-во ссылок в выборке. 0 - без ограничений.
+	Limit int
+	Order SortOrder
+	// Domain Фильтр по домену оригинальной ссылки. Поддомены так же удовлетворяют фильтру.
+	Domain string
+	// Contains Фильтр по подстроке оригинальной ссылки или заголовка (без учета регистра).
+	Contains string
+	// Tag Фильтр по тегу.
+	Tag string
+	// Trash Выбирать удаленные ссылки (содержимое корзины) вместо действующих.
+	Trash bool
+}
+
+// LinkPage Страница ссылок пользователя.
+type LinkPage struct {
+	Links []Link
+	// NextCursor Курсор следующей страницы. Пустая строка, если страница последняя.
+	NextCursor string
+}
+
+// Follows Возвращает true, если ссылка с данным ID следует за курсором.
+func (q *UserLinksQuery) Follows(id LinkID) bool {
+	if q.After == nil {
+		return true
+	}
+	if q.Order == SortDesc {
+		return id < *q.After
+	}
+	return id > *q.After
+}
+
+// Match Возвращает true, если ссылка удовлетворяет фильтрам.
+func (q *UserLinksQuery) Match(link UserLink) bool {
+	if q.Domain != "" && !matchDomain(link.OriginalURL, q.Domain) {
+		return false
+	}
+	if q.Contains != "" && !containsFold(link.OriginalURL, q.Contains) && !containsFold(link.Title, q.Contains) {
+		return false
+	}
+	if q.Tag != "" && !slices.Contains(link.Tags, strings.ToLower(q.Tag)) {
+		return false
+	}
+	return true
+}
+
+// Apply Применяет изменения к метаданным ссылки.
+func (p *LinkPatch) Apply(link *UserLink) {
+	if p.Title != nil {
+		link.Title = *p.Title
+	}
+	if p.Note != nil {
+		link.Note = *p.Note
+	}
+	if p.Tags != nil {
+		link.Tags = *p.Tags
+	}
+}
+
+// Normalize Проверяет изменения и приводит теги к каноническому виду.
+func (p *LinkPatch) Normalize() error {
+	if p.Title != nil {
+		title := strings.TrimSpace(*p.Title)
+		if utf8.RuneCountInString(title) > MaxTitleLen {
+			return ErrInvalidLinkPatch
+		}
+		p.Title = &title
+	}
+
+	if p.Note != nil && utf8.RuneCountInString(*p.Note) > MaxNoteLen {
+		return ErrInvalidLinkPatch
+	}
+
+	if p.Tags != nil {
+		tags, err := NormalizeTags(*p.Tags)
+		if err != nil {
+			return err
+		}
+		p.Tags = &tags
+	}
+
+	if p.RedirectStatus != nil && *p.RedirectStatus != 0 && !IsRedirectStatus(*p.RedirectStatus) {
+		return ErrInvalidLinkPatch
+	}
+
+	if p.Routing != nil {
+		if err := p.Routing.Normalize(); err != nil {
+			return err
+		}
+	}
+
+	if p.Variants != nil {
+		if err := p.Variants.Normalize(); err != nil {
+			return err
+		}
+	}
+
+	if p.OriginalURL != nil {
+		originalURL, err := NormalizeOriginalURL(*p.OriginalURL)
+		if err != nil {
+			return err
+		}
+		p.OriginalURL = &originalURL
+	}
+	return nil
 }
 
+// NormalizeTags Приводит теги к нижнему регистру, удаляет дубликаты и сортирует.
+func NormalizeTags(tags []string) ([]string, error) {
+	res := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || utf8.RuneCountInString(tag) > MaxTagLen {
+			return nil, ErrInvalidLinkPatch
+		}
+		if !slices.Contains(res, tag) {
+			res = append(res, tag)
+		}
+	}
+
+	if len(res) > MaxTagCount {
+		return nil, ErrInvalidLinkPatch
+	}
+
+	slices.Sort(res)
+	return res, nil
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// EncodeCursor Кодирует ID ссылки в курсор.
+func EncodeCursor(id LinkID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(id), 10)))
+}
+
+// DecodeCursor Декодирует курсор, полученный с помощью EncodeCursor.
+func DecodeCursor(cursor string) (LinkID, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseUint(string(data), 10, 64)
+	if err != nil || LinkID(id) > MaxLinkID {
+		return 0, ErrInvalidCursor
+	}
+	return LinkID(id), nil
+}
+
+func matchDomain(originalURL string, domain string) bool {
+	u, err := url.Parse(originalURL)
+	if err != nil {
+		return false
+	}
+
+	return matchHost(strings.TrimSuffix(strings.ToLower(u.Hostname()), "."), domain)
+}
+
+// matchHost Проверяет, что хост в нижнем регистре без завершающей точки совпадает с доменом или его поддоменом.
+func matchHost(host string, domain string) bool {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// NormalizeOriginalURL Проверяет оригинальную ссылку: она должна быть абсолютной ссылкой или абсолютным путем.
 func NormalizeOriginalURL(originalURL string) (string, error) {
+	u, err := parseOriginalURL(originalURL)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func parseOriginalURL(originalURL string) (*url.URL, error) {
 	if originalURL == "" {
-		return "", ErrInvalidURL
+		return nil, ErrInvalidURL
 	}
-	u, err := url.ParseRequestURI(originalURL)
+
+	// ParseRequestURI не выделяет фрагмент, а экранирует # как часть пути.
+	requestURI, fragment, hasFragment := strings.Cut(originalURL, "#")
+	u, err := url.ParseRequestURI(requestURI)
 	if err != nil {
-		return "", ErrInvalidURL
+		return nil, ErrInvalidURL
 	}
-	return u.String(), nil
+
+	if hasFragment {
+		f, err := url.Parse("#" + fragment)
+		if err != nil {
+			return nil, ErrInvalidURL
+		}
+		u.Fragment, u.RawFragment = f.Fragment, f.RawFragment
+	}
+	return u, nil
 }
 
 func NormalizeOriginalURLs(originalURLs []string) ([]string, error) {
@@ -0,0 +1,36 @@
+package model
+
+import "testing"
+
+// FuzzNormalizeOriginalURL Начальный корпус - testdata/fuzz/FuzzNormalizeOriginalURL.
+func FuzzNormalizeOriginalURL(f *testing.F) {
+	f.Fuzz(func(t *testing.T, originalURL string) {
+		normalized, err := NormalizeOriginalURL(originalURL)
+		if err != nil {
+			return
+		}
+
+		again, err := NormalizeOriginalURL(normalized)
+		if err != nil {
+			t.Fatalf("normalized url %q is invalid: %v", normalized, err)
+		}
+		if again != normalized {
+			t.Fatalf("normalization is not idempotent: %q -> %q -> %q", originalURL, normalized, again)
+		}
+
+		for _, rules := range []URLCanonicalization{DefaultURLCanonicalization, DefaultURLCanonicalization | CanonicalSortQuery} {
+			canonical, err := rules.Canonicalize(originalURL)
+			if err != nil {
+				continue
+			}
+
+			again, err := rules.Canonicalize(canonical)
+			if err != nil {
+				t.Fatalf("%v: canonical url %q is invalid: %v", rules, canonical, err)
+			}
+			if again != canonical {
+				t.Fatalf("%v: canonicalization is not idempotent: %q -> %q -> %q", rules, originalURL, canonical, again)
+			}
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// QRCodeFormat Формат изображения QR-кода.
+type QRCodeFormat string
+
+const (
+	QRCodePNG QRCodeFormat = "png"
+	QRCodeSVG QRCodeFormat = "svg"
+)
+
+// QRCodeLevel Уровень коррекции ошибок QR-кода: L (7%), M (15%), Q (25%) или H (30%).
+type QRCodeLevel string
+
+const (
+	QRCodeLevelL QRCodeLevel = "L"
+	QRCodeLevelM QRCodeLevel = "M"
+	QRCodeLevelQ QRCodeLevel = "Q"
+	QRCodeLevelH QRCodeLevel = "H"
+)
+
+const (
+	DefaultQRCodeSize = 256
+	MinQRCodeSize     = 32
+	// MaxQRCodeSize Максимальный размер изображения. Изображение рисуется при каждом запросе с новыми
+	// параметрами, поэтому размер ограничен, чтобы запросы публичного метода не загружали сервис.
+	MaxQRCodeSize       = 1024
+	DefaultQRCodeMargin = 4
+	MaxQRCodeMargin     = 32
+)
+
+// QRCodeOptions Параметры изображения QR-кода короткой ссылки.
+type QRCodeOptions struct {
+	Format QRCodeFormat
+	// Size Ширина и высота изображения в пикселях.
+	Size  int
+	Level QRCodeLevel
+	// Margin Ширина пустой рамки вокруг кода в модулях (клетках кода).
+	Margin     int
+	Foreground color.RGBA
+	Background color.RGBA
+}
+
+// DefaultQRCodeOptions Возвращает параметры QR-кода по умолчанию: черный код на белом фоне.
+func DefaultQRCodeOptions(format QRCodeFormat) QRCodeOptions {
+	return QRCodeOptions{
+		Format:     format,
+		Size:       DefaultQRCodeSize,
+		Level:      QRCodeLevelM,
+		Margin:     DefaultQRCodeMargin,
+		Foreground: color.RGBA{A: 0xff},
+		Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+}
+
+// Validate Проверяет параметры. Возвращает ErrInvalidQRCodeOptions, если параметр вне допустимых значений.
+func (o *QRCodeOptions) Validate() error {
+	switch {
+	case o.Format != QRCodePNG && o.Format != QRCodeSVG,
+		o.Level != QRCodeLevelL && o.Level != QRCodeLevelM && o.Level != QRCodeLevelQ && o.Level != QRCodeLevelH,
+		o.Size < MinQRCodeSize || o.Size > MaxQRCodeSize,
+		o.Margin < 0 || o.Margin > MaxQRCodeMargin:
+		return ErrInvalidQRCodeOptions
+	}
+	return nil
+}
+
+// ParseHexColor Разбирает цвет в записи RGB или RRGGBB, с символом # или без.
+func ParseHexColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return color.RGBA{}, ErrInvalidQRCodeOptions
+	}
+
+	value, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, ErrInvalidQRCodeOptions
+	}
+	return color.RGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 0xff}, nil
+}
+
+// HexColor Возвращает цвет в записи #rrggbb.
+func HexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHexColor(t *testing.T) {
+	c, err := ParseHexColor("#0a0B0c")
+	require.NoError(t, err)
+	assert.Equal(t, color.RGBA{R: 0x0a, G: 0x0b, B: 0x0c, A: 0xff}, c)
+	assert.Equal(t, "#0a0b0c", HexColor(c))
+
+	for _, s := range []string{"", "#12", "12345", "zzzzzz", "+12345"} {
+		_, err = ParseHexColor(s)
+		assert.ErrorIs(t, err, ErrInvalidQRCodeOptions, s)
+	}
+}
+
+func TestQRCodeOptions_Validate(t *testing.T) {
+	opts := DefaultQRCodeOptions(QRCodePNG)
+	require.NoError(t, opts.Validate())
+
+	for _, size := range []int{MinQRCodeSize - 1, MaxQRCodeSize + 1, 4096} {
+		opts.Size = size
+		assert.ErrorIs(t, opts.Validate(), ErrInvalidQRCodeOptions, size)
+	}
+}
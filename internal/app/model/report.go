@@ -0,0 +1,41 @@
+package model
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxReportReasonLen Максимальная длина причины жалобы на ссылку.
+const MaxReportReasonLen = 1024
+
+// LinkReport Жалоба на ссылку и текущее состояние ссылки.
+type LinkReport struct {
+	ID          int64
+	LinkID      LinkID
+	Reason      string
+	CreatedAt   time.Time
+	OriginalURL string
+	// Disabled Ссылка отключена модератором для всех пользователей.
+	Disabled bool
+}
+
+// AbuseReport Жалоба на ссылку в очереди модерации.
+type AbuseReport struct {
+	ID          int64     `json:"id"`
+	ShortURL    string    `json:"short_url"`
+	OriginalURL string    `json:"original_url"`
+	Reason      string    `json:"reason"`
+	CreatedAt   time.Time `json:"created_at"`
+	Disabled    bool      `json:"disabled"`
+}
+
+// NormalizeReportReason Удаляет пробелы по краям причины жалобы и проверяет ее длину.
+// Возвращает ErrInvalidReport, если причина пуста или слишком длинная.
+func NormalizeReportReason(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" || utf8.RuneCountInString(reason) > MaxReportReasonLen {
+		return "", ErrInvalidReport
+	}
+	return reason, nil
+}
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// RewriteRule Правило изменения параметров запроса оригинальной ссылки.
+//
+// Правило применяется к ссылкам пользователей Users (ко всем, если список пуст)
+// на домены Domains и их поддомены (на любые, если список пуст).
+type RewriteRule struct {
+	// Strip Параметры, удаляемые при сокращении ссылки, до дедупликации.
+	// Имя, оканчивающееся на *, задает префикс: utm_* удаляет utm_source, utm_medium и т.д.
+	Strip []string `json:"strip,omitempty"`
+	// Append Параметры вида имя=значение, добавляемые к ссылке при переходе,
+	// если в ссылке еще нет параметра с таким именем.
+	Append []string `json:"append,omitempty"`
+	// Users Пользователи, к ссылкам которых применяется правило. При переходе пользователем ссылки
+	// считается ее автор.
+	Users []UserID `json:"users,omitempty"`
+	// Domains Домены оригинальных ссылок, к которым применяется правило.
+	Domains []string `json:"domains,omitempty"`
+}
+
+// RewriteRules Правила изменения оригинальных ссылок. Правила применяются по порядку.
+type RewriteRules []RewriteRule
+
+// Validate Проверяет правила. Возвращает ErrInvalidRewriteRule, если правило задано неверно.
+func (rules RewriteRules) Validate() error {
+	for _, rule := range rules {
+		for _, name := range rule.Strip {
+			if strings.TrimSuffix(name, "*") == "" {
+				return ErrInvalidRewriteRule
+			}
+		}
+		for _, param := range rule.Append {
+			if name, _, _ := strings.Cut(param, "="); name == "" {
+				return ErrInvalidRewriteRule
+			}
+		}
+	}
+	return nil
+}
+
+// HasUserRules Возвращает true, если хотя бы одно правило добавления параметров ограничено пользователями.
+func (rules RewriteRules) HasUserRules() bool {
+	for _, rule := range rules {
+		if len(rule.Append) > 0 && len(rule.Users) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// StripParams Удаляет из ссылки пользователя параметры правил Strip.
+// Запись остальных параметров и их порядок не меняются.
+func (rules RewriteRules) StripParams(userID UserID, originalURL string) string {
+	return rules.rewrite(userID, originalURL, func(rule *RewriteRule, u *url.URL) {
+		if len(rule.Strip) == 0 || u.RawQuery == "" {
+			return
+		}
+
+		var params []string
+		for _, param := range strings.Split(u.RawQuery, "&") {
+			name, _, _ := strings.Cut(param, "=")
+			if unescaped, err := url.QueryUnescape(name); err == nil {
+				name = unescaped
+			}
+			if !rule.strips(name) {
+				params = append(params, param)
+			}
+		}
+		u.RawQuery = strings.Join(params, "&")
+	})
+}
+
+// AppendParams Добавляет к ссылке автора author параметры правил Append.
+func (rules RewriteRules) AppendParams(author UserID, originalURL string) string {
+	return rules.rewrite(author, originalURL, func(rule *RewriteRule, u *url.URL) {
+		if len(rule.Append) == 0 {
+			return
+		}
+
+		query := u.Query()
+		for _, param := range rule.Append {
+			name, value, _ := strings.Cut(param, "=")
+			if query.Has(name) {
+				continue
+			}
+
+			query.Set(name, value)
+			param = url.QueryEscape(name) + "=" + url.QueryEscape(value)
+			if u.RawQuery == "" {
+				u.RawQuery = param
+			} else {
+				u.RawQuery += "&" + param
+			}
+		}
+	})
+}
+
+func (rules RewriteRules) rewrite(userID UserID, originalURL string, apply func(rule *RewriteRule, u *url.URL)) string {
+	if len(rules) == 0 {
+		return originalURL
+	}
+
+	u, err := url.Parse(originalURL)
+	if err != nil || u.Opaque != "" {
+		return originalURL
+	}
+
+	rawQuery := u.RawQuery
+	for i := range rules {
+		if rules[i].matches(userID, originalURL) {
+			apply(&rules[i], u)
+		}
+	}
+
+	if u.RawQuery == rawQuery {
+		return originalURL
+	}
+	return u.String()
+}
+
+func (rule *RewriteRule) matches(userID UserID, originalURL string) bool {
+	if len(rule.Users) > 0 && !slices.Contains(rule.Users, userID) {
+		return false
+	}
+	if len(rule.Domains) == 0 {
+		return true
+	}
+	return slices.IndexFunc(rule.Domains, func(domain string) bool { return matchDomain(originalURL, domain) }) != -1
+}
+
+func (rule *RewriteRule) strips(name string) bool {
+	for _, pattern := range rule.Strip {
+		if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriteRules_StripParams(t *testing.T) {
+	rules := RewriteRules{
+		{Strip: []string{"utm_*", "fbclid"}},
+		{Strip: []string{"ref"}, Users: []UserID{1}},
+		{Strip: []string{"si"}, Domains: []string{"youtube.com"}},
+	}
+
+	tests := []struct {
+		name   string
+		userID UserID
+		url    string
+		want   string
+	}{
+		{
+			name: "strip tracking params",
+			url:  "https://example.com/a?utm_source=x&id=1&fbclid=abc&utm_medium=y#top",
+			want: "https://example.com/a?id=1#top",
+		},
+		{
+			name: "strip all params",
+			url:  "https://example.com/a?utm_source=x",
+			want: "https://example.com/a",
+		},
+		{
+			name: "keep untracked params as is",
+			url:  "https://example.com/a?b=%20&a=1&ref=2",
+			want: "https://example.com/a?b=%20&a=1&ref=2",
+		},
+		{
+			name:   "user rule",
+			userID: 1,
+			url:    "https://example.com/a?ref=2&a=1",
+			want:   "https://example.com/a?a=1",
+		},
+		{
+			name: "domain rule",
+			url:  "https://www.youtube.com/watch?v=1&si=abc",
+			want: "https://www.youtube.com/watch?v=1",
+		},
+		{
+			name: "other domain",
+			url:  "https://example.com/watch?v=1&si=abc",
+			want: "https://example.com/watch?v=1&si=abc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, rules.StripParams(tt.userID, tt.url))
+		})
+	}
+}
+
+func TestRewriteRules_AppendParams(t *testing.T) {
+	rules := RewriteRules{
+		{Append: []string{"utm_source=short links", "utm_medium=link"}},
+		{Append: []string{"utm_campaign=user1", "utm_medium=other"}, Users: []UserID{1}},
+	}
+
+	assert.Equal(t, "https://example.com/a?id=1&utm_source=short+links&utm_medium=link#top",
+		rules.AppendParams(0, "https://example.com/a?id=1#top"))
+	assert.Equal(t, "https://example.com/?utm_source=own&utm_medium=link&utm_campaign=user1",
+		rules.AppendParams(1, "https://example.com/?utm_source=own"))
+	assert.True(t, rules.HasUserRules())
+
+	assert.Equal(t, "https://example.com/a", RewriteRules(nil).AppendParams(0, "https://example.com/a"))
+}
+
+func TestRewriteRules_Validate(t *testing.T) {
+	assert.NoError(t, RewriteRules{{Strip: []string{"utm_*"}, Append: []string{"a=", "b=1"}}}.Validate())
+	assert.ErrorIs(t, RewriteRules{{Strip: []string{"*"}}}.Validate(), ErrInvalidRewriteRule)
+	assert.ErrorIs(t, RewriteRules{{Append: []string{"=1"}}}.Validate(), ErrInvalidRewriteRule)
+}
@@ -0,0 +1,225 @@
+package model
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Platform Платформа устройства, с которого переходят по ссылке.
+type Platform string
+
+const (
+	PlatformIOS     Platform = "ios"
+	PlatformAndroid Platform = "android"
+	PlatformDesktop Platform = "desktop"
+	// PlatformOther Платформа не определена, например у роботов и консольных клиентов.
+	PlatformOther Platform = "other"
+)
+
+// MaxRoutingRules Максимальное кол-во правил перехода по ссылке.
+const MaxRoutingRules = 32
+
+// DetectPlatform Определяет платформу по заголовку User-Agent.
+func DetectPlatform(userAgent string) Platform {
+	ua := strings.ToLower(userAgent)
+	switch {
+	// Android проверяется первым: User-Agent некоторых Android-браузеров содержит "like iPhone".
+	case strings.Contains(ua, "android"):
+		return PlatformAndroid
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return PlatformIOS
+	case strings.Contains(ua, "windows"), strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os x"),
+		strings.Contains(ua, "x11"), strings.Contains(ua, "linux"), strings.Contains(ua, "cros"):
+		return PlatformDesktop
+	default:
+		return PlatformOther
+	}
+}
+
+// Visit Параметры запроса перехода по короткой ссылке, по которым выбирается оригинальная ссылка.
+type Visit struct {
+	UserAgent      string
+	AcceptLanguage string
+	Query          url.Values
+	// VisitorID Идентификатор посетителя, по которому выбирается вариант ссылки (см. Variants.Pick).
+	VisitorID uint32
+	// IP Адрес посетителя, по которому определяется Country.
+	IP net.IP
+	// Country ISO-код страны посетителя в верхнем регистре. Пустая строка, если страна не определена.
+	Country string
+}
+
+// Click Переход по ссылке, учитываемый в статистике.
+type Click struct {
+	// Variant Вариант ссылки, на который перешел посетитель. Пустая строка, если вариантов нет.
+	Variant string
+	// Country ISO-код страны посетителя. Пустая строка, если страна не определена.
+	Country string
+}
+
+// RoutingRule Правило выбора оригинальной ссылки при переходе. Правило выполняется, если выполнены
+// все заданные в нем условия, правило без условий выполняется всегда.
+type RoutingRule struct {
+	// Platforms Платформы устройства, одна из которых должна совпасть с платформой посетителя.
+	Platforms []Platform `json:"platforms,omitempty"`
+	// Languages Языки, один из которых должен совпасть с предпочтительным языком посетителя
+	// из Accept-Language. Язык без региона (en) совпадает с любым регионом (en-US, en-GB).
+	Languages []string `json:"languages,omitempty"`
+	// Countries ISO-коды стран, одна из которых должна совпасть со страной посетителя.
+	// Правило со странами не выполняется, если страну посетителя определить не удалось.
+	Countries []string `json:"countries,omitempty"`
+	// QueryParam Параметр, который должен присутствовать в запросе короткой ссылки, например app для /{code}?app.
+	QueryParam string `json:"query_param,omitempty"`
+	// OriginalURL Оригинальная ссылка, на которую ведет правило.
+	OriginalURL string `json:"original_url"`
+}
+
+// RoutingRules Правила перехода по ссылке. Применяется первое выполненное правило,
+// если не выполнено ни одно, переход ведет на оригинальную ссылку.
+type RoutingRules []RoutingRule
+
+// Normalize Проверяет правила и приводит платформы и языки к нижнему регистру.
+// Возвращает ErrInvalidRoutingRule, если правило задано неверно.
+func (r RoutingRules) Normalize() error {
+	if len(r) > MaxRoutingRules {
+		return ErrInvalidRoutingRule
+	}
+
+	for i := range r {
+		rule := &r[i]
+		for j, platform := range rule.Platforms {
+			platform = Platform(strings.ToLower(string(platform)))
+			switch platform {
+			case PlatformIOS, PlatformAndroid, PlatformDesktop, PlatformOther:
+			default:
+				return ErrInvalidRoutingRule
+			}
+			rule.Platforms[j] = platform
+		}
+
+		for j, lang := range rule.Languages {
+			lang = strings.ToLower(strings.TrimSpace(lang))
+			if lang == "" || lang == "*" || strings.ContainsAny(lang, ",; ") {
+				return ErrInvalidRoutingRule
+			}
+			rule.Languages[j] = lang
+		}
+
+		for j, country := range rule.Countries {
+			country = strings.ToUpper(strings.TrimSpace(country))
+			if !IsCountryCode(country) {
+				return ErrInvalidRoutingRule
+			}
+			rule.Countries[j] = country
+		}
+
+		rule.QueryParam = strings.TrimSpace(rule.QueryParam)
+
+		originalURL, err := NormalizeOriginalURL(rule.OriginalURL)
+		if err != nil {
+			return err
+		}
+		rule.OriginalURL = originalURL
+	}
+	return nil
+}
+
+// Route Возвращает оригинальную ссылку первого правила, выполненного для visit.
+func (r RoutingRules) Route(visit Visit) (string, bool) {
+	if len(r) == 0 {
+		return "", false
+	}
+
+	platform := DetectPlatform(visit.UserAgent)
+	lang := PreferredLanguage(visit.AcceptLanguage)
+	for _, rule := range r {
+		if rule.match(platform, lang, visit) {
+			return rule.OriginalURL, true
+		}
+	}
+	return "", false
+}
+
+func (r *RoutingRule) match(platform Platform, lang string, visit Visit) bool {
+	if len(r.Platforms) > 0 {
+		found := false
+		for _, p := range r.Platforms {
+			found = found || p == platform
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(r.Languages) > 0 {
+		found := false
+		for _, l := range r.Languages {
+			found = found || l == lang || strings.HasPrefix(lang, l+"-")
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(r.Countries) > 0 {
+		found := false
+		for _, c := range r.Countries {
+			found = found || c == visit.Country
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return r.QueryParam == "" || visit.Query.Has(r.QueryParam)
+}
+
+// LinkDestinations Возвращает все оригинальные ссылки, на которые может вести короткая ссылка:
+// оригинальную ссылку, затем ссылки правил перехода и вариантов.
+func LinkDestinations(originalURL string, routing RoutingRules, variants Variants) []string {
+	res := make([]string, 0, 1+len(routing)+len(variants))
+	res = append(res, originalURL)
+	for _, rule := range routing {
+		res = append(res, rule.OriginalURL)
+	}
+	for _, variant := range variants {
+		res = append(res, variant.OriginalURL)
+	}
+	return res
+}
+
+// IsCountryCode Проверяет, что code - двухбуквенный ISO-код страны в верхнем регистре.
+func IsCountryCode(code string) bool {
+	return len(code) == 2 && code[0] >= 'A' && code[0] <= 'Z' && code[1] >= 'A' && code[1] <= 'Z'
+}
+
+// PreferredLanguage Возвращает язык с наибольшим весом из заголовка Accept-Language в нижнем регистре.
+// Из языков с равным весом выбирается первый. Пустая строка, если язык не указан.
+func PreferredLanguage(acceptLanguage string) string {
+	var (
+		preferred string
+		maxWeight float64
+	)
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		lang, params, _ := strings.Cut(part, ";")
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if lang == "" || lang == "*" {
+			continue
+		}
+
+		weight := 1.0
+		params = strings.TrimSpace(params)
+		if q := strings.TrimPrefix(params, "q="); q != params {
+			var err error
+			if weight, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if weight > maxWeight {
+			preferred, maxWeight = lang, weight
+		}
+	}
+	return preferred
+}
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	iPhoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 16_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.5 Mobile/15E148 Safari/604.1"
+	androidUA = "Mozilla/5.0 (Linux; Android 13; Pixel 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Mobile Safari/537.36"
+	desktopUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Safari/537.36"
+)
+
+func TestDetectPlatform(t *testing.T) {
+	assert.Equal(t, PlatformIOS, DetectPlatform(iPhoneUA))
+	assert.Equal(t, PlatformIOS, DetectPlatform("Mozilla/5.0 (iPad; CPU OS 12_2 like Mac OS X)"))
+	assert.Equal(t, PlatformAndroid, DetectPlatform(androidUA))
+	assert.Equal(t, PlatformDesktop, DetectPlatform(desktopUA))
+	assert.Equal(t, PlatformDesktop, DetectPlatform("Mozilla/5.0 (Macintosh; Intel Mac OS X 13_5) Safari/605.1.15"))
+	assert.Equal(t, PlatformDesktop, DetectPlatform("Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:109.0) Firefox/117.0"))
+	assert.Equal(t, PlatformOther, DetectPlatform("curl/8.1.2"))
+	assert.Equal(t, PlatformOther, DetectPlatform(""))
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	assert.Equal(t, "de-ch", PreferredLanguage("de-CH, en;q=0.9"))
+	assert.Equal(t, "en", PreferredLanguage("fr;q=0.5, en;q=0.8, *;q=0.9"))
+	assert.Equal(t, "ru", PreferredLanguage("ru, en"))
+	assert.Equal(t, "en", PreferredLanguage("ru;q=bad, en;q=0.1"))
+	assert.Equal(t, "", PreferredLanguage(""))
+	assert.Equal(t, "", PreferredLanguage("en;q=0"))
+}
+
+func TestRoutingRules_Route(t *testing.T) {
+	rules := RoutingRules{
+		{QueryParam: "web", OriginalURL: "https://example.com/"},
+		{Platforms: []Platform{PlatformDesktop}, Countries: []string{"DE"}, OriginalURL: "https://example.de/"},
+		{Platforms: []Platform{PlatformIOS}, Languages: []string{"ru"}, OriginalURL: "https://apps.apple.com/ru/app/id1"},
+		{Platforms: []Platform{PlatformIOS}, OriginalURL: "https://apps.apple.com/app/id1"},
+		{Platforms: []Platform{PlatformAndroid}, OriginalURL: "https://play.google.com/store/apps/details?id=app"},
+	}
+
+	tests := []struct {
+		name  string
+		visit Visit
+		want  string
+	}{
+		{
+			name:  "platform",
+			visit: Visit{UserAgent: androidUA},
+			want:  "https://play.google.com/store/apps/details?id=app",
+		},
+		{
+			name:  "platform and region of language",
+			visit: Visit{UserAgent: iPhoneUA, AcceptLanguage: "ru-RU,ru;q=0.9,en;q=0.8"},
+			want:  "https://apps.apple.com/ru/app/id1",
+		},
+		{
+			name:  "language is not preferred",
+			visit: Visit{UserAgent: iPhoneUA, AcceptLanguage: "en-US,ru;q=0.5"},
+			want:  "https://apps.apple.com/app/id1",
+		},
+		{
+			name:  "first matching rule",
+			visit: Visit{UserAgent: iPhoneUA, Query: url.Values{"web": {""}}},
+			want:  "https://example.com/",
+		},
+		{
+			name:  "country",
+			visit: Visit{UserAgent: desktopUA, Country: "DE"},
+			want:  "https://example.de/",
+		},
+		{
+			name:  "no matching rule",
+			visit: Visit{UserAgent: desktopUA},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := rules.Route(tt.visit)
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	got, ok := RoutingRules{{OriginalURL: "https://example.com/"}}.Route(Visit{})
+	assert.True(t, ok)
+	assert.Equal(t, "https://example.com/", got)
+}
+
+func TestRoutingRules_Normalize(t *testing.T) {
+	rules := RoutingRules{{
+		Platforms:   []Platform{"iOS"},
+		Languages:   []string{" EN-us "},
+		Countries:   []string{"us"},
+		OriginalURL: "https://example.com",
+	}}
+	assert.NoError(t, rules.Normalize())
+	assert.Equal(t, RoutingRules{{
+		Platforms:   []Platform{PlatformIOS},
+		Languages:   []string{"en-us"},
+		Countries:   []string{"US"},
+		OriginalURL: "https://example.com",
+	}}, rules)
+
+	invalid := []RoutingRules{
+		{{Platforms: []Platform{"windows"}, OriginalURL: "https://example.com"}},
+		{{Languages: []string{"*"}, OriginalURL: "https://example.com"}},
+		{{Languages: []string{"en,ru"}, OriginalURL: "https://example.com"}},
+		{{Countries: []string{"USA"}, OriginalURL: "https://example.com"}},
+		{{Countries: []string{""}, OriginalURL: "https://example.com"}},
+		make(RoutingRules, MaxRoutingRules+1),
+	}
+	for _, rules := range invalid {
+		assert.ErrorIs(t, rules.Normalize(), ErrInvalidRoutingRule)
+	}
+	assert.ErrorIs(t, RoutingRules{{OriginalURL: ""}}.Normalize(), ErrInvalidURL)
+}
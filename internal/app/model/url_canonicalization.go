@@ -0,0 +1,321 @@
+package model
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// URLCanonicalization Набор правил приведения оригинальных ссылок к каноническому виду.
+//
+// Ссылки, совпадающие после применения правил, считаются одной ссылкой при дедупликации.
+// Схема приводится к нижнему регистру всегда, независимо от правил.
+type URLCanonicalization uint
+
+const (
+	// CanonicalLowercaseHost Приводит хост к нижнему регистру: HTTP://Example.com -> http://example.com.
+	CanonicalLowercaseHost URLCanonicalization = 1 << iota
+	// CanonicalStripDefaultPort Удаляет порт по умолчанию для схемы: http://example.com:80 -> http://example.com.
+	CanonicalStripDefaultPort
+	// CanonicalResolveDotSegments Разрешает сегменты . и .. в пути: /a/./b/../c -> /a/c.
+	CanonicalResolveDotSegments
+	// CanonicalNormalizeEscapes Декодирует экранированные незарезервированные символы
+	// и приводит остальные к верхнему регистру: /%7euser/%2f -> /~user/%2F.
+	CanonicalNormalizeEscapes
+	// CanonicalPunycode Записывает интернационализированные домены в punycode: пример.рф -> xn--e1afmkfd.xn--p1ai.
+	CanonicalPunycode
+	// CanonicalStripFragment Удаляет фрагмент: /a#frag -> /a.
+	CanonicalStripFragment
+	// CanonicalSortQuery Сортирует параметры запроса по имени: ?b=1&a=2 -> ?a=2&b=1.
+	// Порядок параметров с одинаковым именем сохраняется.
+	CanonicalSortQuery
+)
+
+// DefaultURLCanonicalization Правила, не меняющие ресурс, на который указывает ссылка. Фрагмент не удаляется:
+// в одностраничных приложениях он задает страницу (#/route), поэтому CanonicalStripFragment включается явно.
+const DefaultURLCanonicalization = CanonicalLowercaseHost | CanonicalStripDefaultPort | CanonicalResolveDotSegments |
+	CanonicalNormalizeEscapes | CanonicalPunycode
+
+var urlCanonicalizationNames = []struct {
+	rule URLCanonicalization
+	name string
+}{
+	{CanonicalLowercaseHost, "lowercase-host"},
+	{CanonicalStripDefaultPort, "strip-default-port"},
+	{CanonicalResolveDotSegments, "resolve-dot-segments"},
+	{CanonicalNormalizeEscapes, "normalize-escapes"},
+	{CanonicalPunycode, "punycode"},
+	{CanonicalStripFragment, "strip-fragment"},
+	{CanonicalSortQuery, "sort-query"},
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+	"ftp":   "21",
+}
+
+// Canonicalize Проверяет оригинальную ссылку и приводит ее к каноническому виду.
+func (c URLCanonicalization) Canonicalize(originalURL string) (string, error) {
+	u, err := parseOriginalURL(originalURL)
+	if err != nil {
+		return "", err
+	}
+	if c == 0 {
+		return u.String(), nil
+	}
+
+	if u.Host != "" {
+		if err = c.canonicalizeHost(u); err != nil {
+			return "", err
+		}
+	}
+
+	if u.Opaque == "" {
+		if err = c.canonicalizePath(u); err != nil {
+			return "", err
+		}
+	}
+
+	if c&CanonicalNormalizeEscapes != 0 {
+		u.RawQuery = normalizeEscapes(u.RawQuery)
+	}
+	if c&CanonicalSortQuery != 0 {
+		u.RawQuery = sortQuery(u.RawQuery)
+	}
+	u.ForceQuery = u.ForceQuery && u.RawQuery == ""
+
+	if c&CanonicalStripFragment != 0 {
+		u.Fragment, u.RawFragment = "", ""
+	} else if c&CanonicalNormalizeEscapes != 0 && u.Fragment != "" {
+		u.RawFragment = normalizeEscapes(u.EscapedFragment())
+		if u.Fragment, err = url.PathUnescape(u.RawFragment); err != nil {
+			return "", ErrInvalidURL
+		}
+	}
+
+	return u.String(), nil
+}
+
+// CanonicalizeAll Проверяет оригинальные ссылки и приводит их к каноническому виду.
+func (c URLCanonicalization) CanonicalizeAll(originalURLs []string) ([]string, error) {
+	if len(originalURLs) == 0 {
+		return nil, nil
+	}
+
+	res := make([]string, len(originalURLs))
+	for i, originalURL := range originalURLs {
+		canonical, err := c.Canonicalize(originalURL)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = canonical
+	}
+	return res, nil
+}
+
+func (c URLCanonicalization) canonicalizeHost(u *url.URL) error {
+	host, port := u.Hostname(), u.Port()
+	bracketed := strings.HasPrefix(u.Host, "[")
+	hasPort := strings.LastIndexByte(u.Host, ':') > strings.LastIndexByte(u.Host, ']')
+
+	// Разбор допускает хосты вроде "::" и ":80", у которых нет канонической записи.
+	if host == "" || !bracketed && strings.Contains(host, ":") {
+		return ErrInvalidURL
+	}
+
+	if c&CanonicalLowercaseHost != 0 {
+		host = strings.ToLower(host)
+	}
+
+	if c&CanonicalPunycode != 0 && !isASCII(host) {
+		ascii, err := idna.Punycode.ToASCII(host)
+		if err != nil {
+			return ErrInvalidURL
+		}
+		host = ascii
+	}
+
+	if c&CanonicalStripDefaultPort != 0 && (port == "" || port == defaultPorts[u.Scheme]) {
+		hasPort = false
+	}
+
+	if bracketed {
+		host = "[" + host + "]"
+	}
+	if hasPort {
+		host += ":" + port
+	}
+	u.Host = host
+	return nil
+}
+
+func (c URLCanonicalization) canonicalizePath(u *url.URL) error {
+	path := u.EscapedPath()
+	if c&CanonicalNormalizeEscapes != 0 {
+		path = normalizeEscapes(path)
+	}
+	if c&CanonicalResolveDotSegments != 0 {
+		path = removeDotSegments(path)
+	}
+
+	unescaped, err := url.PathUnescape(path)
+	if err != nil {
+		return ErrInvalidURL
+	}
+	u.Path, u.RawPath = unescaped, path
+	return nil
+}
+
+func (c URLCanonicalization) String() string {
+	if c == 0 {
+		return "none"
+	}
+
+	var names []string
+	for _, it := range urlCanonicalizationNames {
+		if c&it.rule != 0 {
+			names = append(names, it.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// UnmarshalText Разбирает список правил через запятую. Пустой список и none отключают все правила.
+func (c *URLCanonicalization) UnmarshalText(text []byte) error {
+	var res URLCanonicalization
+	for _, name := range strings.Split(string(text), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == "none" {
+			continue
+		}
+
+		found := false
+		for _, it := range urlCanonicalizationNames {
+			if it.name == name {
+				res |= it.rule
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown url canonicalization rule: %q", name)
+		}
+	}
+
+	*c = res
+	return nil
+}
+
+// normalizeEscapes Декодирует экранированные незарезервированные символы (RFC 3986, раздел 6.2.2.2)
+// и приводит шестнадцатеричные цифры остальных к верхнему регистру.
+func normalizeEscapes(str string) string {
+	if !strings.Contains(str, "%") {
+		return str
+	}
+
+	var b strings.Builder
+	b.Grow(len(str))
+	for i := 0; i < len(str); i++ {
+		if str[i] != '%' || i+2 >= len(str) || !isHex(str[i+1]) || !isHex(str[i+2]) {
+			b.WriteByte(str[i])
+			continue
+		}
+
+		c := unhex(str[i+1])<<4 | unhex(str[i+2])
+		if isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteString(strings.ToUpper(str[i+1 : i+3]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+// removeDotSegments Удаляет сегменты . и .. из пути (RFC 3986, раздел 5.2.4).
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+
+	var out []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			// Первый сегмент абсолютного пути пустой, его не удаляем.
+			if len(out) > 1 || len(out) == 1 && out[0] != "" {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, segment)
+		}
+	}
+
+	res := strings.Join(out, "/")
+	if strings.HasPrefix(path, "/") && !strings.HasPrefix(res, "/") {
+		res = "/" + res
+	}
+	return res
+}
+
+// sortQuery Сортирует параметры запроса по имени, не меняя их запись.
+func sortQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return rawQuery
+	}
+
+	params := strings.Split(rawQuery, "&")
+	name := func(param string) string {
+		if idx := strings.IndexByte(param, '='); idx != -1 {
+			return param[:idx]
+		}
+		return param
+	}
+	sort.SliceStable(params, func(i, j int) bool { return name(params[i]) < name(params[j]) })
+	return strings.Join(params, "&")
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
+
+func isASCII(str string) bool {
+	for i := 0; i < len(str); i++ {
+		if str[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
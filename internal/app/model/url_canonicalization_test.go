@@ -0,0 +1,128 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLCanonicalization_Canonicalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules URLCanonicalization
+		url   string
+		want  string
+	}{
+		{
+			name:  "default rules",
+			rules: DefaultURLCanonicalization,
+			url:   "HTTP://Example.COM:80/a/./b/../c/%7euser/%2f?q=%4a%2b#frag",
+			want:  "http://example.com/a/c/~user/%2F?q=J%2B#frag",
+		},
+		{
+			name:  "no rules",
+			rules: 0,
+			url:   "HTTP://Example.COM:80/a/./b/../c#frag",
+			want:  "http://Example.COM:80/a/./b/../c#frag",
+		},
+		{
+			name:  "lowercase host",
+			rules: CanonicalLowercaseHost,
+			url:   "http://Example.COM:80/A",
+			want:  "http://example.com:80/A",
+		},
+		{
+			name:  "strip default port",
+			rules: CanonicalStripDefaultPort,
+			url:   "https://example.com:443/",
+			want:  "https://example.com/",
+		},
+		{
+			name:  "keep non-default port",
+			rules: CanonicalStripDefaultPort,
+			url:   "https://example.com:80/",
+			want:  "https://example.com:80/",
+		},
+		{
+			name:  "strip empty port",
+			rules: CanonicalStripDefaultPort,
+			url:   "http://[::1]:/",
+			want:  "http://[::1]/",
+		},
+		{
+			name:  "resolve dot segments",
+			rules: CanonicalResolveDotSegments,
+			url:   "http://example.com/a/b/../../../c/./d/.",
+			want:  "http://example.com/c/d/",
+		},
+		{
+			name:  "normalize escapes",
+			rules: CanonicalNormalizeEscapes,
+			url:   "http://example.com/%41%2f%2e?a=%7e%3d#%61%3f",
+			want:  "http://example.com/A%2F.?a=~%3D#a%3F",
+		},
+		{
+			name:  "punycode",
+			rules: CanonicalPunycode,
+			url:   "http://пример.рф/путь",
+			want:  "http://xn--e1afmkfd.xn--p1ai/%D0%BF%D1%83%D1%82%D1%8C",
+		},
+		{
+			name:  "strip fragment",
+			rules: CanonicalStripFragment,
+			url:   "http://example.com/a#frag",
+			want:  "http://example.com/a",
+		},
+		{
+			name:  "sort query",
+			rules: CanonicalSortQuery,
+			url:   "http://example.com/?b=2&a=1&b=1&c",
+			want:  "http://example.com/?a=1&b=2&b=1&c",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rules.Canonicalize(tt.url)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := DefaultURLCanonicalization.Canonicalize("not a url")
+	assert.ErrorIs(t, err, ErrInvalidURL)
+}
+
+func TestURLCanonicalization_Dedup(t *testing.T) {
+	urls := []string{"HTTP://Example.com:80/a", "http://example.com/a"}
+	for _, u := range urls {
+		got, err := DefaultURLCanonicalization.Canonicalize(u)
+		require.NoError(t, err)
+		assert.Equal(t, "http://example.com/a", got)
+	}
+
+	// Фрагмент по умолчанию сохраняется: в одностраничных приложениях он задает страницу.
+	got, err := DefaultURLCanonicalization.Canonicalize("https://app.example/#/settings")
+	require.NoError(t, err)
+	assert.Equal(t, "https://app.example/#/settings", got)
+
+	got, err = (DefaultURLCanonicalization | CanonicalStripFragment).Canonicalize("http://example.com/a#frag")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/a", got)
+}
+
+func TestURLCanonicalization_UnmarshalText(t *testing.T) {
+	var rules URLCanonicalization
+	require.NoError(t, rules.UnmarshalText([]byte(DefaultURLCanonicalization.String())))
+	assert.Equal(t, DefaultURLCanonicalization, rules)
+
+	require.NoError(t, rules.UnmarshalText([]byte("sort-query, lowercase-host")))
+	assert.Equal(t, CanonicalSortQuery|CanonicalLowercaseHost, rules)
+
+	require.NoError(t, rules.UnmarshalText([]byte("none")))
+	assert.Equal(t, URLCanonicalization(0), rules)
+	assert.Equal(t, "none", rules.String())
+
+	assert.Error(t, rules.UnmarshalText([]byte("lowercase-host,unknown")))
+}
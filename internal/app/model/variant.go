@@ -0,0 +1,109 @@
+package model
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxVariants Максимальное кол-во вариантов оригинальной ссылки.
+	MaxVariants    = 16
+	MaxVariantName = 64
+	// MaxVariantWeight Максимальный вес варианта. Веса задаются в любых единицах, например 70 и 30 или 7 и 3.
+	MaxVariantWeight = 10000
+)
+
+// Variant Вариант оригинальной ссылки для A/B-теста.
+type Variant struct {
+	Name        string `json:"name"`
+	OriginalURL string `json:"original_url"`
+	// Weight Доля переходов, которые ведут на вариант, относительно суммы весов всех вариантов. 0 - вариант выключен.
+	Weight int `json:"weight"`
+	// Clicks Кол-во переходов на вариант. Задается сервисом, при изменении вариантов игнорируется.
+	Clicks int64 `json:"clicks"`
+}
+
+// Variants Варианты оригинальной ссылки, между которыми распределяются переходы.
+type Variants []Variant
+
+// Normalize Проверяет варианты: имена должны быть уникальными, хотя бы один вариант должен иметь
+// ненулевой вес. Возвращает ErrInvalidVariant, если варианты заданы неверно.
+func (v Variants) Normalize() error {
+	if len(v) > MaxVariants {
+		return ErrInvalidVariant
+	}
+
+	total := 0
+	names := make(map[string]bool, len(v))
+	for i := range v {
+		variant := &v[i]
+		variant.Name = strings.TrimSpace(variant.Name)
+		if variant.Name == "" || utf8.RuneCountInString(variant.Name) > MaxVariantName || names[variant.Name] {
+			return ErrInvalidVariant
+		}
+		names[variant.Name] = true
+
+		if variant.Weight < 0 || variant.Weight > MaxVariantWeight {
+			return ErrInvalidVariant
+		}
+		total += variant.Weight
+
+		originalURL, err := NormalizeOriginalURL(variant.OriginalURL)
+		if err != nil {
+			return err
+		}
+		variant.OriginalURL = originalURL
+		variant.Clicks = 0
+	}
+
+	if len(v) > 0 && total == 0 {
+		return ErrInvalidVariant
+	}
+	return nil
+}
+
+// Pick Выбирает вариант для посетителя. Выбор зависит только от ссылки, посетителя и весов, поэтому
+// посетитель попадает на один и тот же вариант при каждом переходе. Посетитель попадает в точку
+// фиксированного отрезка, который делится между вариантами пропорционально весам, поэтому при изменении
+// весов на другой вариант переходят только посетители из изменившейся доли отрезка,
+// даже если изменилась сумма весов (70/30 -> 60/30).
+func (v Variants) Pick(linkID LinkID, visitorID uint32) (Variant, bool) {
+	var total uint64
+	for _, variant := range v {
+		total += uint64(variant.Weight)
+	}
+	if total == 0 {
+		return Variant{}, false
+	}
+
+	var key [12]byte
+	binary.BigEndian.PutUint64(key[:8], uint64(linkID))
+	binary.BigEndian.PutUint32(key[8:], visitorID)
+	h := fnv.New64a()
+	h.Write(key[:])
+
+	// Отрезок [0, 2^32): граница варианта - накопленная доля весов. Сумма весов не превосходит
+	// MaxVariants*MaxVariantWeight, поэтому произведение помещается в uint64.
+	point := mixHash(h.Sum64()) >> 32
+	var cumulative uint64
+	for _, variant := range v {
+		cumulative += uint64(variant.Weight)
+		if point < cumulative<<32/total {
+			return variant, true
+		}
+	}
+	return Variant{}, false
+}
+
+// mixHash Перемешивает биты хеша (финализатор MurmurHash3): от последних байтов ключа в FNV зависят
+// только отдельные биты хеша.
+func mixHash(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
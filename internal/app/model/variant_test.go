@@ -0,0 +1,75 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVariants_Pick(t *testing.T) {
+	variants := Variants{
+		{Name: "a", OriginalURL: "https://example.com/a", Weight: 70},
+		{Name: "off", OriginalURL: "https://example.com/off"},
+		{Name: "b", OriginalURL: "https://example.com/b", Weight: 30},
+	}
+
+	counts := make(map[string]int)
+	for visitorID := uint32(0); visitorID < 10000; visitorID++ {
+		variant, ok := variants.Pick(1, visitorID)
+		require.True(t, ok)
+		counts[variant.Name]++
+
+		// Посетитель всегда попадает на один и тот же вариант.
+		again, _ := variants.Pick(1, visitorID)
+		assert.Equal(t, variant.Name, again.Name)
+	}
+	assert.InDelta(t, 7000, counts["a"], 300)
+	assert.InDelta(t, 3000, counts["b"], 300)
+	assert.Zero(t, counts["off"])
+
+	_, ok := Variants{}.Pick(1, 1)
+	assert.False(t, ok)
+}
+
+func TestVariants_PickStable(t *testing.T) {
+	before := Variants{
+		{Name: "a", OriginalURL: "https://example.com/a", Weight: 70},
+		{Name: "b", OriginalURL: "https://example.com/b", Weight: 30},
+	}
+	// Сумма весов изменилась: доля a уменьшилась с 70% до 2/3.
+	after := Variants{
+		{Name: "a", OriginalURL: "https://example.com/a", Weight: 60},
+		{Name: "b", OriginalURL: "https://example.com/b", Weight: 30},
+	}
+
+	moved := 0
+	for visitorID := uint32(0); visitorID < 10000; visitorID++ {
+		was, _ := before.Pick(1, visitorID)
+		now, _ := after.Pick(1, visitorID)
+		if was.Name != now.Name {
+			assert.Equal(t, "a", was.Name, "only visitors of the shrunk variant move")
+			moved++
+		}
+	}
+	assert.InDelta(t, 333, moved, 100)
+}
+
+func TestVariants_Normalize(t *testing.T) {
+	variants := Variants{{Name: " a ", OriginalURL: "https://example.com/a", Weight: 1, Clicks: 10}}
+	require.NoError(t, variants.Normalize())
+	assert.Equal(t, Variants{{Name: "a", OriginalURL: "https://example.com/a", Weight: 1}}, variants)
+
+	invalid := []Variants{
+		{{Name: "", OriginalURL: "https://example.com", Weight: 1}},
+		{{Name: "a", OriginalURL: "https://example.com", Weight: 1}, {Name: "a", OriginalURL: "https://example.com", Weight: 1}},
+		{{Name: "a", OriginalURL: "https://example.com", Weight: -1}},
+		{{Name: "a", OriginalURL: "https://example.com", Weight: MaxVariantWeight + 1}},
+		{{Name: "a", OriginalURL: "https://example.com"}},
+		make(Variants, MaxVariants+1),
+	}
+	for _, variants := range invalid {
+		assert.ErrorIs(t, variants.Normalize(), ErrInvalidVariant)
+	}
+	assert.NoError(t, Variants{}.Normalize())
+}
@@ -3,19 +3,22 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"strings"
 	"time"
 
 	"github.com/ikashurnikov/shortener/internal/app/model"
 )
 
 type dbRepo struct {
-	db *sql.DB
+	db   *sql.DB
+	opts options
 }
 
-func NewDBRepo(dsn string) (*dbRepo, error) {
+func NewDBRepo(dsn string, opts ...Option) (*dbRepo, error) {
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
@@ -24,7 +27,7 @@ func NewDBRepo(dsn string) (*dbRepo, error) {
 	if err = initDatabase(db); err != nil {
 		return nil, err
 	}
-	return &dbRepo{db: db}, nil
+	return &dbRepo{db: db, opts: newOptions(opts)}, nil
 }
 
 func (repo *dbRepo) AddUser() (model.UserID, error) {
@@ -67,7 +70,7 @@ func (repo *dbRepo) saveOriginalURLs(userID model.UserID, origURLs []string, alr
 	}
 	defer tx.Rollback()
 
-	linkIDs, err := repo.doSaveOriginalURLs(tx, origURLs, alreadyExists)
+	linkIDs, err := repo.doSaveOriginalURLs(tx, userID, origURLs, alreadyExists)
 	if err != nil {
 		return nil, err
 	}
@@ -79,28 +82,62 @@ func (repo *dbRepo) saveOriginalURLs(userID model.UserID, origURLs []string, alr
 	return linkIDs, tx.Commit()
 }
 
-func (repo *dbRepo) doSaveOriginalURLs(tx *sql.Tx, origURLs []string, alreadyExists *bool) ([]model.LinkID, error) {
+func (repo *dbRepo) doSaveOriginalURLs(tx *sql.Tx, userID model.UserID, origURLs []string, alreadyExists *bool) ([]model.LinkID, error) {
 	if len(origURLs) == 0 {
 		return nil, nil
 	}
 
+	// NULL в dedup_key не конфликтует ни с одним ключом, поэтому такая ссылка всегда добавляется.
+	// ID из последовательности выбирается, только если ссылки с таким ключом нет. Если ее одновременно
+	// добавила другая транзакция, выбранный ID остается неиспользованным.
 	q := `WITH ins AS(
-    	INSERT INTO links ("original_url") VALUES ($1) 
-    		ON CONFLICT("original_url") DO NOTHING
+    	INSERT INTO links ("link_id", "original_url", "dedup_key") VALUES ($4, $1, $3)
+    		ON CONFLICT("dedup_key") DO NOTHING
     	RETURNING link_id, true as is_new
+	), ver AS(
+		INSERT INTO link_versions ("link_id", "version", "original_url", "author")
+			SELECT link_id, 1, $1, $2 FROM ins
 	)
 	SELECT * FROM ins
 	UNION
-	  SELECT link_id, false as is_new FROM links WHERE original_url=$1;`
+	  SELECT link_id, false as is_new FROM links WHERE dedup_key=$3;`
 
 	stmt, err := tx.Prepare(q)
 	if err != nil {
 		return nil, err
 	}
 
+	findStmt, err := tx.Prepare(`SELECT link_id FROM links WHERE dedup_key=$1;`)
+	if err != nil {
+		return nil, err
+	}
+
 	res := make([]model.LinkID, 0, len(origURLs))
 	for _, origURL := range origURLs {
-		row := stmt.QueryRow(origURL)
+		dedupKey := sql.NullString{String: repo.opts.dedupMode.DedupKey(userID, origURL)}
+		dedupKey.Valid = dedupKey.String != ""
+
+		if dedupKey.Valid {
+			var id model.LinkID
+			err := findStmt.QueryRow(dedupKey).Scan(&id)
+			if err == nil {
+				if alreadyExists != nil {
+					*alreadyExists = true
+				}
+				res = append(res, id)
+				continue
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return nil, err
+			}
+		}
+
+		linkID, err := repo.nextLinkID(tx)
+		if err != nil {
+			return nil, err
+		}
+
+		row := stmt.QueryRow(origURL, userID, dedupKey, linkID)
 
 		var id model.LinkID
 		var isNew bool
@@ -119,6 +156,23 @@ func (repo *dbRepo) doSaveOriginalURLs(tx *sql.Tx, origURLs []string, alreadyExi
 	return res, nil
 }
 
+// nextLinkID Выбирает из последовательности ID новой ссылки, пропуская ID, не прошедшие фильтр.
+func (repo *dbRepo) nextLinkID(tx *sql.Tx) (model.LinkID, error) {
+	for skipped := 0; skipped <= maxSkippedLinkIDs; skipped++ {
+		row := tx.QueryRow(`SELECT nextval(pg_get_serial_sequence('links', 'link_id'))`)
+
+		var linkID model.LinkID
+		if err := row.Scan(&linkID); err != nil {
+			return 0, err
+		}
+
+		if repo.opts.acceptLinkID(linkID) {
+			return linkID, nil
+		}
+	}
+	return 0, model.ErrNoFreeLinkID
+}
+
 func (repo *dbRepo) saveUserLinks(tx *sql.Tx, userID model.UserID, linkIDs []model.LinkID) error {
 	if len(linkIDs) == 0 {
 		return nil
@@ -127,7 +181,7 @@ func (repo *dbRepo) saveUserLinks(tx *sql.Tx, userID model.UserID, linkIDs []mod
 	q := `
 	INSERT INTO user_links("user_id", "link_id") VALUES ($1, $2) 
 		ON CONFLICT("user_id", "link_id") 
-	DO UPDATE SET deleted=FALSE WHERE user_links.user_id=$1 AND user_links.link_id=$2`
+	DO UPDATE SET deleted=FALSE, deleted_at=NULL WHERE user_links.user_id=$1 AND user_links.link_id=$2`
 
 	stmt, err := tx.Prepare(q)
 	if err != nil {
@@ -144,10 +198,13 @@ func (repo *dbRepo) saveUserLinks(tx *sql.Tx, userID model.UserID, linkIDs []mod
 }
 
 func (repo *dbRepo) GetOriginalURLByID(id model.LinkID) (string, error) {
-	row := repo.db.QueryRow("SELECT original_url FROM links WHERE link_id=$1", id)
+	row := repo.db.QueryRow("SELECT original_url, disabled FROM links WHERE link_id=$1", id)
 
-	var origURL string
-	err := row.Scan(&origURL)
+	var (
+		origURL  string
+		disabled bool
+	)
+	err := row.Scan(&origURL, &disabled)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return "", model.ErrLinkNotFound
@@ -155,6 +212,10 @@ func (repo *dbRepo) GetOriginalURLByID(id model.LinkID) (string, error) {
 		return "", err
 	}
 
+	if disabled {
+		return origURL, model.ErrLinkDisabled
+	}
+
 	row = repo.db.QueryRow("SELECT user_id FROM user_links WHERE link_id=$1 AND deleted=FALSE LIMIT 1", id)
 	var userID int
 	err = row.Scan(&userID)
@@ -168,6 +229,107 @@ func (repo *dbRepo) GetOriginalURLByID(id model.LinkID) (string, error) {
 	return origURL, nil
 }
 
+func (repo *dbRepo) GetLinkAuthor(id model.LinkID) (model.UserID, error) {
+	q := `SELECT COALESCE(author, -1) FROM link_versions WHERE link_id=$1 AND version=1`
+	row := repo.db.QueryRow(q, id)
+
+	var author model.UserID
+	if err := row.Scan(&author); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.InvalidUserID, model.ErrLinkNotFound
+		}
+		return model.InvalidUserID, err
+	}
+	return author, nil
+}
+
+func (repo *dbRepo) GetLinkDetails(id model.LinkID) (model.LinkDetails, error) {
+	q := `
+	SELECT COALESCE(user_links.title, ''), COALESCE(user_links.note, ''),
+		links.clicks, ` + linkCountryClicksColumn + `, links.interstitial, links.redirect_status, links.routing,
+		` + linkVariantsColumn + `
+	FROM links
+	  LEFT JOIN link_versions ON link_versions.link_id=links.link_id AND link_versions.version=1
+	  LEFT JOIN user_links ON user_links.link_id=links.link_id
+		AND user_links.user_id=link_versions.author AND user_links.deleted=FALSE
+	WHERE links.link_id=$1`
+
+	var details model.LinkDetails
+	err := repo.db.QueryRow(q, id).Scan(&details.Title, &details.Note,
+		&details.Clicks, jsonColumn{&details.CountryClicks}, &details.Interstitial, &details.RedirectStatus,
+		jsonColumn{&details.Routing}, jsonColumn{&details.Variants})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.LinkDetails{}, model.ErrLinkNotFound
+		}
+		return model.LinkDetails{}, err
+	}
+	return details, nil
+}
+
+func (repo *dbRepo) CountClick(id model.LinkID, click model.Click) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE links SET clicks=clicks+1 WHERE link_id=$1", id)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return model.ErrLinkNotFound
+	}
+
+	if click.Variant != "" {
+		q := "UPDATE link_variants SET clicks=clicks+1 WHERE link_id=$1 AND name=$2"
+		if _, err = tx.Exec(q, id, click.Variant); err != nil {
+			return err
+		}
+	}
+
+	if click.Country != "" {
+		q := `
+		INSERT INTO link_countries(link_id, country, clicks) VALUES($1, $2, 1)
+		ON CONFLICT (link_id, country) DO UPDATE SET clicks=link_countries.clicks+1`
+		if _, err = tx.Exec(q, id, click.Country); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (repo *dbRepo) ForEachLink(fn func(id model.LinkID, originalURLs []string) error) error {
+	q := `SELECT link_id, original_url, routing, ` + linkVariantsColumn + ` FROM links ORDER BY link_id`
+	rows, err := repo.db.Query(q)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id          model.LinkID
+			originalURL string
+			routing     model.RoutingRules
+			variants    model.Variants
+		)
+		if err = rows.Scan(&id, &originalURL, jsonColumn{&routing}, jsonColumn{&variants}); err != nil {
+			return err
+		}
+		if err = fn(id, model.LinkDestinations(originalURL, routing, variants)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 func (repo *dbRepo) GetOriginalURLsByUserID(id model.UserID) (map[string]model.LinkID, error) {
 	q := `
 	SELECT links.link_id, links.original_url FROM links 
@@ -201,6 +363,303 @@ func (repo *dbRepo) GetOriginalURLsByUserID(id model.UserID) (map[string]model.L
 	return res, nil
 }
 
+func (repo *dbRepo) GetUserLinks(id model.UserID, query model.UserLinksQuery) ([]model.UserLink, error) {
+	q := `
+	SELECT ` + userLinkColumns + ` FROM links
+	  INNER JOIN user_links ON links.link_id = user_links.link_id
+	WHERE user_links.user_id=$1 AND deleted=$2`
+
+	args := []interface{}{id, query.Trash}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	// Keyset-пагинация по link_id.
+	order, cmp := "ASC", ">"
+	if query.Order == model.SortDesc {
+		order, cmp = "DESC", "<"
+	}
+	if query.After != nil {
+		q += fmt.Sprintf(" AND links.link_id %s %s", cmp, arg(*query.After))
+	}
+
+	if query.Domain != "" {
+		// Хост и домен сравниваются без завершающей точки, как в model.UserLinksQuery.Match.
+		host := `rtrim(lower(substring(links.original_url from '^[^:]+://(?:[^/?#@]*@)?([^/?#:]+)')), '.')`
+		domain := arg(strings.TrimSuffix(strings.ToLower(query.Domain), "."))
+		q += fmt.Sprintf(" AND (%[1]s = %[2]s OR right(%[1]s, length(%[2]s) + 1) = '.' || %[2]s)", host, domain)
+	}
+
+	if query.Contains != "" {
+		contains := arg(query.Contains)
+		q += fmt.Sprintf(
+			" AND (strpos(lower(links.original_url), lower(%[1]s)) > 0 OR strpos(lower(user_links.title), lower(%[1]s)) > 0)",
+			contains)
+	}
+
+	if query.Tag != "" {
+		q += fmt.Sprintf(" AND %s = ANY(user_links.tags)", arg(strings.ToLower(query.Tag)))
+	}
+
+	q += " ORDER BY links.link_id " + order
+	if query.Limit > 0 {
+		q += " LIMIT " + arg(query.Limit)
+	}
+
+	rows, err := repo.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	res := make([]model.UserLink, 0)
+	for rows.Next() {
+		link, err := scanUserLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, link)
+	}
+
+	return res, rows.Err()
+}
+
+func (repo *dbRepo) UpdateUserLink(userID model.UserID, linkID model.LinkID, patch model.LinkPatch) (model.UserLink, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return model.UserLink{}, err
+	}
+	defer tx.Rollback()
+
+	if patch.OriginalURL != nil || patch.HasLinkSettings() {
+		if err = updateLink(tx, userID, linkID, patch); err != nil {
+			return model.UserLink{}, err
+		}
+	}
+
+	var tags interface{}
+	if patch.Tags != nil {
+		tags = pq.Array(*patch.Tags)
+	}
+
+	q := `
+	WITH upd AS (
+		UPDATE user_links SET
+			title=COALESCE($3, title),
+			note=COALESCE($4, note),
+			tags=COALESCE($5, tags)
+		WHERE user_id=$1 AND link_id=$2 AND deleted=FALSE
+		RETURNING *
+	)
+	SELECT ` + userLinkColumns + ` FROM upd AS user_links
+	  INNER JOIN links ON links.link_id = user_links.link_id`
+
+	link, err := scanUserLink(tx.QueryRow(q, userID, linkID, patch.Title, patch.Note, tags))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.UserLink{}, model.ErrLinkNotFound
+		}
+		return model.UserLink{}, err
+	}
+	return link, tx.Commit()
+}
+
+func (repo *dbRepo) RetargetLink(userID model.UserID, linkID model.LinkID, originalURL string) (model.UserLink, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return model.UserLink{}, err
+	}
+	defer tx.Rollback()
+
+	if err = updateLink(tx, userID, linkID, model.LinkPatch{OriginalURL: &originalURL}); err != nil {
+		return model.UserLink{}, err
+	}
+
+	link, err := getUserLink(tx, userID, linkID)
+	if err != nil {
+		return model.UserLink{}, err
+	}
+	return link, tx.Commit()
+}
+
+func (repo *dbRepo) UpdateLinkSettings(userID model.UserID, linkID model.LinkID, patch model.LinkPatch) (model.UserLink, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return model.UserLink{}, err
+	}
+	defer tx.Rollback()
+
+	patch.OriginalURL = nil
+	if err = updateLink(tx, userID, linkID, patch); err != nil {
+		return model.UserLink{}, err
+	}
+
+	link, err := getUserLink(tx, userID, linkID)
+	if err != nil {
+		return model.UserLink{}, err
+	}
+	return link, tx.Commit()
+}
+
+// updateLink Изменяет оригинальную ссылку и общие настройки ссылки (см. model.LinkPatch.HasLinkSettings).
+// Метаданные пользователя не изменяются. Изменить ссылку может только ее единственный владелец.
+func updateLink(tx *sql.Tx, userID model.UserID, linkID model.LinkID, patch model.LinkPatch) error {
+	var curURL string
+	row := tx.QueryRow("SELECT original_url FROM links WHERE link_id=$1 FOR UPDATE", linkID)
+	if err := row.Scan(&curURL); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ErrLinkNotFound
+		}
+		return err
+	}
+
+	if err := checkSoleOwner(tx, userID, linkID); err != nil {
+		return err
+	}
+
+	if patch.OriginalURL != nil && *patch.OriginalURL != curURL {
+		_, err := tx.Exec("UPDATE links SET original_url=$2, dedup_key=NULL WHERE link_id=$1", linkID, *patch.OriginalURL)
+		if err != nil {
+			return err
+		}
+
+		q := `
+		INSERT INTO link_versions ("link_id", "version", "original_url", "author")
+			SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3 FROM link_versions WHERE link_id=$1`
+
+		if _, err = tx.Exec(q, linkID, *patch.OriginalURL, userID); err != nil {
+			return err
+		}
+	}
+
+	if !patch.HasLinkSettings() {
+		return nil
+	}
+
+	q := `
+	UPDATE links SET
+		interstitial=COALESCE($2, interstitial),
+		redirect_status=COALESCE($3, redirect_status),
+		routing=CASE WHEN $4 THEN $5::jsonb ELSE routing END
+	WHERE link_id=$1`
+
+	var routing sql.NullString
+	if patch.Routing != nil && len(*patch.Routing) > 0 {
+		data, err := json.Marshal(*patch.Routing)
+		if err != nil {
+			return err
+		}
+		routing = sql.NullString{String: string(data), Valid: true}
+	}
+
+	if _, err := tx.Exec(q, linkID, patch.Interstitial, patch.RedirectStatus, patch.Routing != nil, routing); err != nil {
+		return err
+	}
+
+	if patch.Variants != nil {
+		return updateLinkVariants(tx, linkID, *patch.Variants)
+	}
+	return nil
+}
+
+// updateLinkVariants Заменяет варианты ссылки. У вариантов с прежними именами сохраняется кол-во переходов.
+func updateLinkVariants(tx *sql.Tx, linkID model.LinkID, variants model.Variants) error {
+	names := make([]string, len(variants))
+	for i, variant := range variants {
+		names[i] = variant.Name
+	}
+
+	q := `DELETE FROM link_variants WHERE link_id=$1 AND NOT (name = ANY($2))`
+	if _, err := tx.Exec(q, linkID, pq.Array(names)); err != nil {
+		return err
+	}
+
+	q = `
+	INSERT INTO link_variants(link_id, name, position, original_url, weight) VALUES($1, $2, $3, $4, $5)
+	ON CONFLICT (link_id, name) DO UPDATE
+	SET position=EXCLUDED.position, original_url=EXCLUDED.original_url, weight=EXCLUDED.weight`
+
+	stmt, err := tx.Prepare(q)
+	if err != nil {
+		return err
+	}
+
+	for i, variant := range variants {
+		if _, err = stmt.Exec(linkID, variant.Name, i, variant.OriginalURL, variant.Weight); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSoleOwner Проверяет, что у ссылки нет других пользователей, кроме userID.
+// Ссылки других пользователей, даже удаленные, делают ссылку общей.
+func checkSoleOwner(tx *sql.Tx, userID model.UserID, linkID model.LinkID) error {
+	q := `
+	SELECT
+		COUNT(*) FILTER (WHERE user_id=$2 AND deleted=FALSE),
+		COUNT(*) FILTER (WHERE user_id<>$2)
+	FROM user_links WHERE link_id=$1`
+
+	var owned, others int
+	if err := tx.QueryRow(q, linkID, userID).Scan(&owned, &others); err != nil {
+		return err
+	}
+	if owned == 0 {
+		return model.ErrLinkNotFound
+	}
+	if others != 0 {
+		return model.ErrLinkShared
+	}
+	return nil
+}
+
+func getUserLink(tx *sql.Tx, userID model.UserID, linkID model.LinkID) (model.UserLink, error) {
+	q := `
+	SELECT ` + userLinkColumns + ` FROM links
+	  INNER JOIN user_links ON links.link_id = user_links.link_id
+	WHERE user_links.user_id=$1 AND user_links.link_id=$2`
+
+	return scanUserLink(tx.QueryRow(q, userID, linkID))
+}
+
+func (repo *dbRepo) GetLinkHistory(userID model.UserID, linkID model.LinkID) ([]model.LinkVersion, error) {
+	q := `
+	SELECT version, original_url, created_at, COALESCE(author, -1) FROM link_versions
+	WHERE link_id=$2 AND EXISTS(
+		SELECT 1 FROM user_links WHERE user_id=$1 AND link_id=$2 AND deleted=FALSE)
+	ORDER BY version`
+
+	rows, err := repo.db.Query(q, userID, linkID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	res := make([]model.LinkVersion, 0)
+	for rows.Next() {
+		var version model.LinkVersion
+		err = rows.Scan(&version.Version, &version.OriginalURL, &version.CreatedAt, &version.Author)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, version)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, model.ErrLinkNotFound
+	}
+	return res, nil
+}
+
 func (repo *dbRepo) DeleteURLs(userID model.UserID, linkIDs []model.LinkID) error {
 	tx, err := repo.db.Begin()
 	if err != nil {
@@ -208,7 +667,30 @@ func (repo *dbRepo) DeleteURLs(userID model.UserID, linkIDs []model.LinkID) erro
 	}
 	defer tx.Rollback()
 
-	q := `UPDATE user_links SET deleted=TRUE WHERE user_id=$1 AND link_id=$2`
+	q := `UPDATE user_links SET deleted=TRUE, deleted_at=now() WHERE user_id=$1 AND link_id=$2 AND deleted=FALSE`
+
+	stmt, err := tx.Prepare(q)
+	if err != nil {
+		return err
+	}
+
+	for _, linkID := range linkIDs {
+		if _, err := stmt.Exec(userID, linkID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (repo *dbRepo) RestoreURLs(userID model.UserID, linkIDs []model.LinkID) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := `UPDATE user_links SET deleted=FALSE, deleted_at=NULL WHERE user_id=$1 AND link_id=$2 AND deleted=TRUE`
 
 	stmt, err := tx.Prepare(q)
 	if err != nil {
@@ -224,6 +706,116 @@ func (repo *dbRepo) DeleteURLs(userID model.UserID, linkIDs []model.LinkID) erro
 	return tx.Commit()
 }
 
+func (repo *dbRepo) SetLinkDisabled(linkID model.LinkID, disabled bool) error {
+	res, err := repo.db.Exec("UPDATE links SET disabled=$2 WHERE link_id=$1", linkID, disabled)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return model.ErrLinkNotFound
+	}
+	return nil
+}
+
+func (repo *dbRepo) AddReport(linkID model.LinkID, reason string) (model.LinkReport, error) {
+	q := `
+	WITH report AS (
+		INSERT INTO link_reports("link_id", "reason")
+		SELECT link_id, $2 FROM links WHERE link_id=$1
+		RETURNING report_id, link_id, reason, created_at
+	)
+	SELECT report.report_id, report.link_id, report.reason, report.created_at, links.original_url, links.disabled
+	FROM report JOIN links ON links.link_id=report.link_id`
+
+	report, err := scanLinkReport(repo.db.QueryRow(q, linkID, reason))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.LinkReport{}, model.ErrLinkNotFound
+		}
+		return model.LinkReport{}, err
+	}
+	return report, nil
+}
+
+func (repo *dbRepo) GetReports() ([]model.LinkReport, error) {
+	q := `
+	SELECT link_reports.report_id, link_reports.link_id, link_reports.reason, link_reports.created_at,
+		links.original_url, links.disabled
+	FROM link_reports JOIN links ON links.link_id=link_reports.link_id
+	ORDER BY link_reports.report_id`
+
+	rows, err := repo.db.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]model.LinkReport, 0)
+	for rows.Next() {
+		report, err := scanLinkReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, report)
+	}
+	return res, rows.Err()
+}
+
+func scanLinkReport(row rowScanner) (model.LinkReport, error) {
+	var report model.LinkReport
+	err := row.Scan(&report.ID, &report.LinkID, &report.Reason, &report.CreatedAt, &report.OriginalURL, &report.Disabled)
+	return report, err
+}
+
+func (repo *dbRepo) PurgeDeletedURLs(deletedBefore time.Time) (int, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(
+		"DELETE FROM user_links WHERE deleted=TRUE AND deleted_at < $1 RETURNING link_id", deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	linkIDs := make([]int64, 0)
+	for rows.Next() {
+		var linkID int64
+		if err = rows.Scan(&linkID); err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+		linkIDs = append(linkIDs, linkID)
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(linkIDs) == 0 {
+		return 0, nil
+	}
+
+	// Изменения первого запроса видны только следующим запросам транзакции,
+	// поэтому ссылки без пользователей удаляются отдельным запросом.
+	q := `
+	DELETE FROM links WHERE link_id = ANY($1) AND NOT EXISTS(
+		SELECT 1 FROM user_links WHERE user_links.link_id=links.link_id)`
+
+	if _, err = tx.Exec(q, pq.Array(linkIDs)); err != nil {
+		return 0, err
+	}
+
+	return len(linkIDs), tx.Commit()
+}
+
 func (repo *dbRepo) Ping() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
@@ -238,6 +830,53 @@ func (repo *dbRepo) Close() error {
 	return repo.db.Close()
 }
 
+const userLinkColumns = `links.link_id, user_links.user_id, links.original_url,
+	user_links.created_at, user_links.title, user_links.note, user_links.tags, user_links.deleted_at,
+	links.clicks, ` + linkCountryClicksColumn + `, links.interstitial, links.redirect_status, links.routing,
+	` + linkVariantsColumn
+
+// linkCountryClicksColumn Кол-во переходов по странам в формате JSON. NULL, если переходов из известных стран нет.
+const linkCountryClicksColumn = `(
+	SELECT json_object_agg(link_countries.country, link_countries.clicks)
+	FROM link_countries WHERE link_countries.link_id=links.link_id)`
+
+// linkVariantsColumn Варианты ссылки в формате JSON (см. model.Variants). NULL, если вариантов нет.
+const linkVariantsColumn = `(
+	SELECT json_agg(json_build_object('name', link_variants.name, 'original_url', link_variants.original_url,
+		'weight', link_variants.weight, 'clicks', link_variants.clicks) ORDER BY link_variants.position)
+	FROM link_variants WHERE link_variants.link_id=links.link_id)`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// jsonColumn Разбирает значение колонки в формате JSON в dest. NULL оставляет dest без изменений.
+type jsonColumn struct {
+	dest interface{}
+}
+
+func (c jsonColumn) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(src, c.dest)
+	case string:
+		return json.Unmarshal([]byte(src), c.dest)
+	default:
+		return fmt.Errorf("unsupported json column type: %T", src)
+	}
+}
+
+func scanUserLink(row rowScanner) (model.UserLink, error) {
+	var link model.UserLink
+	err := row.Scan(&link.ID, &link.UserID, &link.OriginalURL,
+		&link.CreatedAt, &link.Title, &link.Note, (*pq.StringArray)(&link.Tags), &link.DeletedAt,
+		&link.Clicks, jsonColumn{&link.CountryClicks}, &link.Interstitial, &link.RedirectStatus,
+		jsonColumn{&link.Routing}, jsonColumn{&link.Variants})
+	return link, err
+}
+
 func initDatabase(db *sql.DB) error {
 	createTable := func(q string) error {
 		var err error
@@ -246,14 +885,51 @@ func initDatabase(db *sql.DB) error {
 	}
 
 	urlsTable := `CREATE TABLE IF NOT EXISTS links(
-	 	link_id SERIAL NOT NULL,
-	 	original_url TEXT NOT NULL UNIQUE,
+	 	link_id BIGSERIAL NOT NULL,
+	 	original_url TEXT NOT NULL,
+	 	dedup_key TEXT UNIQUE,
      	PRIMARY KEY (link_id))`
 
 	if err := createTable(urlsTable); err != nil {
 		return err
 	}
 
+	// Ранее ключом дедупликации служила сама оригинальная ссылка (original_url UNIQUE).
+	dedupKeyMigration := []string{
+		`ALTER TABLE links ADD COLUMN IF NOT EXISTS dedup_key TEXT UNIQUE`,
+		`UPDATE links SET dedup_key=original_url WHERE dedup_key IS NULL AND EXISTS(
+			SELECT 1 FROM pg_constraint WHERE conname='links_original_url_key')`,
+		`ALTER TABLE links DROP CONSTRAINT IF EXISTS links_original_url_key`,
+	}
+
+	for _, q := range dedupKeyMigration {
+		if err := createTable(q); err != nil {
+			return err
+		}
+	}
+
+	// Отключение ссылки модератором не зависит от удаления ссылки пользователями.
+	disabledMigration := `ALTER TABLE links ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT FALSE`
+
+	if err := createTable(disabledMigration); err != nil {
+		return err
+	}
+
+	linkStatsMigration := `ALTER TABLE links
+		ADD COLUMN IF NOT EXISTS clicks BIGINT NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS interstitial BOOLEAN NOT NULL DEFAULT FALSE,
+		ADD COLUMN IF NOT EXISTS redirect_status INTEGER NOT NULL DEFAULT 0`
+
+	if err := createTable(linkStatsMigration); err != nil {
+		return err
+	}
+
+	routingMigration := `ALTER TABLE links ADD COLUMN IF NOT EXISTS routing JSONB`
+
+	if err := createTable(routingMigration); err != nil {
+		return err
+	}
+
 	usersTable := `CREATE TABLE IF NOT EXISTS users(
 		user_id SERIAL NOT NULL,
 		PRIMARY KEY (user_id))`
@@ -264,7 +940,7 @@ func initDatabase(db *sql.DB) error {
 
 	userURLsTable := `CREATE TABLE IF NOT EXISTS user_links(
 		user_id INTEGER NOT NULL,
-		link_id  INTEGER NOT NULL,
+		link_id  BIGINT NOT NULL,
 		deleted BOOLEAN NOT NULL DEFAULT FALSE,
 		UNIQUE(user_id, link_id),
 		CONSTRAINT fk_user_id
@@ -278,6 +954,114 @@ func initDatabase(db *sql.DB) error {
 	if err := createTable(userURLsTable); err != nil {
 		return err
 	}
+
+	userURLsMetadata := `ALTER TABLE user_links
+		ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		ADD COLUMN IF NOT EXISTS title TEXT NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS note TEXT NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}',
+		ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ`
+
+	if err := createTable(userURLsMetadata); err != nil {
+		return err
+	}
+
+	// Срок хранения ссылок, удаленных до появления deleted_at, отсчитывается с момента миграции.
+	deletedAtMigration := `UPDATE user_links SET deleted_at=now() WHERE deleted=TRUE AND deleted_at IS NULL`
+
+	if err := createTable(deletedAtMigration); err != nil {
+		return err
+	}
+
+	linkVersionsTable := `CREATE TABLE IF NOT EXISTS link_versions(
+		link_id BIGINT NOT NULL,
+		version INTEGER NOT NULL,
+		original_url TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		author INTEGER,
+		PRIMARY KEY (link_id, version),
+		CONSTRAINT fk_link_id
+			FOREIGN KEY(link_id) REFERENCES links(link_id)
+			ON DELETE CASCADE
+	)`
+
+	if err := createTable(linkVersionsTable); err != nil {
+		return err
+	}
+
+	// Ссылки, сохраненные до появления истории, получают первую версию.
+	linkVersionsMigration := `INSERT INTO link_versions ("link_id", "version", "original_url", "author")
+		SELECT link_id, 1, original_url,
+			(SELECT MIN(user_id) FROM user_links WHERE user_links.link_id=links.link_id)
+		FROM links
+		WHERE NOT EXISTS(SELECT 1 FROM link_versions WHERE link_versions.link_id=links.link_id)`
+
+	if err := createTable(linkVersionsMigration); err != nil {
+		return err
+	}
+
+	// Ранее ID ссылок были 32-битными (SERIAL).
+	bigintMigration := `DO $$
+	BEGIN
+		IF (SELECT data_type FROM information_schema.columns
+			WHERE table_schema=current_schema() AND table_name='links' AND column_name='link_id') <> 'bigint'
+		THEN
+			ALTER TABLE links ALTER COLUMN link_id TYPE BIGINT;
+			ALTER SEQUENCE links_link_id_seq AS BIGINT;
+			ALTER TABLE user_links ALTER COLUMN link_id TYPE BIGINT;
+			ALTER TABLE link_versions ALTER COLUMN link_id TYPE BIGINT;
+		END IF;
+	END $$`
+
+	if err := createTable(bigintMigration); err != nil {
+		return err
+	}
+
+	linkReportsTable := `CREATE TABLE IF NOT EXISTS link_reports(
+		report_id BIGSERIAL NOT NULL,
+		link_id BIGINT NOT NULL,
+		reason TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (report_id),
+		CONSTRAINT fk_link_id
+			FOREIGN KEY(link_id) REFERENCES links(link_id)
+			ON DELETE CASCADE
+	)`
+
+	if err := createTable(linkReportsTable); err != nil {
+		return err
+	}
+
+	linkVariantsTable := `CREATE TABLE IF NOT EXISTS link_variants(
+		link_id BIGINT NOT NULL,
+		name TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		original_url TEXT NOT NULL,
+		weight INTEGER NOT NULL,
+		clicks BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (link_id, name),
+		CONSTRAINT fk_link_id
+			FOREIGN KEY(link_id) REFERENCES links(link_id)
+			ON DELETE CASCADE
+	)`
+
+	if err := createTable(linkVariantsTable); err != nil {
+		return err
+	}
+
+	linkCountriesTable := `CREATE TABLE IF NOT EXISTS link_countries(
+		link_id BIGINT NOT NULL,
+		country CHAR(2) NOT NULL,
+		clicks BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (link_id, country),
+		CONSTRAINT fk_link_id
+			FOREIGN KEY(link_id) REFERENCES links(link_id)
+			ON DELETE CASCADE
+	)`
+
+	if err := createTable(linkCountriesTable); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -290,5 +1074,9 @@ func cleanDB(db *sql.DB) {
 	dropTable("table")
 	dropTable("users")
 	dropTable("user_links")
+	dropTable("link_versions")
+	dropTable("link_reports")
+	dropTable("link_variants")
+	dropTable("link_countries")
 	dropTable("links")
 }
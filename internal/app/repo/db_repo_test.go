@@ -0,0 +1,367 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/ikashurnikov/shortener/internal/app/model"
+	"github.com/stretchr/testify/require"
+)
+
+// testDBSchema Создает для теста отдельную схему в базе DATABASE_DSN и возвращает строку подключения к ней.
+// Схема удаляется по завершении теста. Без DATABASE_DSN тест пропускается.
+func testDBSchema(t *testing.T) (*sql.DB, string) {
+	dsn := os.Getenv("DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("DATABASE_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+
+	schema := "test_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	_, err = db.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, _ = db.Exec("DROP SCHEMA " + schema + " CASCADE")
+		_ = db.Close()
+	})
+
+	if strings.Contains(dsn, "://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + "search_path=" + schema
+	} else {
+		dsn += " search_path=" + schema
+	}
+
+	schemaDB, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = schemaDB.Close() })
+	return schemaDB, dsn
+}
+
+func newTestDBRepo(t *testing.T, opts ...Option) *dbRepo {
+	_, dsn := testDBSchema(t)
+	repo, err := NewDBRepo(dsn, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = repo.Close() })
+	return repo
+}
+
+func TestDBRepo(t *testing.T) {
+	testStorage(func(opts ...Option) Repo {
+		return newTestDBRepo(t, opts...)
+	}, t)
+}
+
+func TestDBRepo_DedupKeepsSequence(t *testing.T) {
+	for _, mode := range []model.DedupMode{model.DedupGlobal, model.DedupPerUser} {
+		repo := newTestDBRepo(t, WithDedupMode(mode))
+		userID, err := repo.AddUser()
+		require.NoError(t, err)
+
+		first, err := repo.SaveOriginalURL(userID, "https://yandex.ru")
+		require.NoError(t, err)
+
+		// Повторное сокращение не расходует ID из последовательности.
+		for i := 0; i < 3; i++ {
+			id, err := repo.SaveOriginalURL(userID, "https://yandex.ru")
+			require.ErrorIs(t, err, model.ErrLinkAlreadyExists, mode)
+			require.Equal(t, first, id, mode)
+		}
+		ids, err := repo.SaveOriginalURLs(userID, []string{"https://yandex.ru", "https://google.com"})
+		require.NoError(t, err)
+		require.Equal(t, []model.LinkID{first, first + 1}, ids, mode)
+	}
+}
+
+// createLegacySchema Создает таблицы в том виде, в котором их создавали первые версии сервиса:
+// 32-битные ID ссылок и уникальная оригинальная ссылка вместо ключа дедупликации.
+func createLegacySchema(t *testing.T, db *sql.DB) {
+	queries := []string{
+		`CREATE TABLE links(
+			link_id SERIAL NOT NULL,
+			original_url TEXT NOT NULL UNIQUE,
+			PRIMARY KEY (link_id))`,
+		`CREATE TABLE users(
+			user_id SERIAL NOT NULL,
+			PRIMARY KEY (user_id))`,
+		`CREATE TABLE user_links(
+			user_id INTEGER NOT NULL,
+			link_id  INTEGER NOT NULL,
+			deleted BOOLEAN NOT NULL DEFAULT FALSE,
+			UNIQUE(user_id, link_id),
+			CONSTRAINT fk_user_id
+				FOREIGN KEY(user_id) REFERENCES users(user_id)
+				ON DELETE CASCADE,
+			CONSTRAINT fk_link_id
+				FOREIGN KEY(link_id) REFERENCES links(link_id)
+				ON DELETE CASCADE)`,
+	}
+	for _, q := range queries {
+		_, err := db.Exec(q)
+		require.NoError(t, err)
+	}
+}
+
+func TestDBRepo_UpdateUserLink(t *testing.T) {
+	repo := newTestDBRepo(t)
+	user := newTestUser(repo, t)
+	other := newTestUser(repo, t)
+
+	user.saveOriginalURL("https://docs.example/v1")
+	linkID := user.links["https://docs.example/v1"]
+
+	// Изменение оригинальной ссылки, настроек и метаданных применяется целиком.
+	originalURL, title, tags := "https://docs.example/v2", "Docs", []string{"docs"}
+	interstitial, status := true, http.StatusPermanentRedirect
+	link, err := repo.UpdateUserLink(user.id, linkID, model.LinkPatch{
+		OriginalURL:    &originalURL,
+		Title:          &title,
+		Tags:           &tags,
+		Interstitial:   &interstitial,
+		RedirectStatus: &status,
+	})
+	require.NoError(t, err)
+	require.Equal(t, originalURL, link.OriginalURL)
+	require.Equal(t, title, link.Title)
+	require.Equal(t, tags, link.Tags)
+	require.True(t, link.Interstitial)
+	require.Equal(t, status, link.RedirectStatus)
+
+	details, err := repo.GetLinkDetails(linkID)
+	require.NoError(t, err)
+	require.Equal(t, title, details.Title)
+	require.True(t, details.Interstitial)
+
+	// Общая ссылка не изменяется частично: метаданные откатываются вместе с оригинальной ссылкой.
+	user.saveOriginalURL("https://shared.example/")
+	other.saveOriginalURL("https://shared.example/")
+	sharedID := user.links["https://shared.example/"]
+	sharedURL, sharedTitle := "https://shared.example/new", "Shared"
+	_, err = repo.UpdateUserLink(user.id, sharedID, model.LinkPatch{OriginalURL: &sharedURL, Title: &sharedTitle})
+	require.ErrorIs(t, err, model.ErrLinkShared)
+
+	links, err := repo.GetUserLinks(user.id, model.UserLinksQuery{Domain: "shared.example"})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	require.Equal(t, "https://shared.example/", links[0].OriginalURL)
+	require.Empty(t, links[0].Title)
+
+	// История и откат к первой версии.
+	history, err := repo.GetLinkHistory(user.id, linkID)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	require.Equal(t, "https://docs.example/v1", history[0].OriginalURL)
+	require.Equal(t, originalURL, history[1].OriginalURL)
+	require.Equal(t, user.id, history[1].Author)
+
+	link, err = repo.UpdateUserLink(user.id, linkID, model.LinkPatch{OriginalURL: &history[0].OriginalURL})
+	require.NoError(t, err)
+	require.Equal(t, "https://docs.example/v1", link.OriginalURL)
+
+	history, err = repo.GetLinkHistory(user.id, linkID)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	require.Equal(t, 3, history[2].Version)
+	require.Equal(t, "https://docs.example/v1", history[2].OriginalURL)
+
+	_, err = repo.GetLinkHistory(other.id, linkID)
+	require.ErrorIs(t, err, model.ErrLinkNotFound)
+}
+
+func TestDBRepo_MigrateLegacySchema(t *testing.T) {
+	db, dsn := testDBSchema(t)
+	createLegacySchema(t, db)
+
+	queries := []string{
+		`INSERT INTO users VALUES (default), (default)`,
+		`INSERT INTO links(original_url) VALUES ('https://yandex.ru'), ('https://google.com')`,
+		`INSERT INTO user_links(user_id, link_id, deleted) VALUES (1, 1, FALSE), (2, 1, FALSE), (1, 2, TRUE)`,
+	}
+	for _, q := range queries {
+		_, err := db.Exec(q)
+		require.NoError(t, err)
+	}
+
+	repo, err := NewDBRepo(dsn)
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	// Повторный запуск миграций ничего не меняет.
+	repo, err = NewDBRepo(dsn)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	var dataType string
+	row := db.QueryRow(`SELECT data_type FROM information_schema.columns
+		WHERE table_schema=current_schema() AND table_name='user_links' AND column_name='link_id'`)
+	require.NoError(t, row.Scan(&dataType))
+	require.Equal(t, "bigint", dataType)
+
+	urls, err := repo.GetOriginalURLsByUserID(2)
+	require.NoError(t, err)
+	require.Equal(t, map[string]model.LinkID{"https://yandex.ru": 1}, urls)
+
+	// Ключом дедупликации становится оригинальная ссылка.
+	id, err := repo.SaveOriginalURL(2, "https://yandex.ru")
+	require.ErrorIs(t, err, model.ErrLinkAlreadyExists)
+	require.Equal(t, model.LinkID(1), id)
+
+	// Ссылки получают первую версию, автор - пользователь с наименьшим ID.
+	history, err := repo.GetLinkHistory(2, 1)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, "https://yandex.ru", history[0].OriginalURL)
+	require.Equal(t, model.UserID(1), history[0].Author)
+
+	// Удаленные ранее ссылки попадают в корзину со временем удаления.
+	trash, err := repo.GetUserLinks(1, model.UserLinksQuery{Trash: true})
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	require.Equal(t, "https://google.com", trash[0].OriginalURL)
+	require.NotNil(t, trash[0].DeletedAt)
+
+	// Новые ссылки продолжают последовательность.
+	id, err = repo.SaveOriginalURL(1, "https://bing.com")
+	require.NoError(t, err)
+	require.Equal(t, model.LinkID(3), id)
+}
+
+func TestDBRepo_BigintLinkIDs(t *testing.T) {
+	db, dsn := testDBSchema(t)
+	createLegacySchema(t, db)
+
+	_, err := db.Exec(`INSERT INTO users VALUES (default)`)
+	require.NoError(t, err)
+
+	repo, err := NewDBRepo(dsn)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	// Ссылки после переполнения 32-битного знакового и беззнакового диапазонов.
+	for i, last := range []int64{math.MaxInt32, math.MaxUint32} {
+		_, err = db.Exec(`SELECT setval(pg_get_serial_sequence('links', 'link_id'), $1)`, last)
+		require.NoError(t, err)
+
+		originalURL := fmt.Sprintf("https://yandex.ru/%d", i)
+		id, err := repo.SaveOriginalURL(1, originalURL)
+		require.NoError(t, err)
+		require.Equal(t, model.LinkID(last+1), id)
+
+		got, err := repo.GetOriginalURLByID(id)
+		require.NoError(t, err)
+		require.Equal(t, originalURL, got)
+
+		history, err := repo.GetLinkHistory(1, id)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+	}
+
+	// ID, не прошедшие фильтр, пропускаются и в 64-битном диапазоне.
+	filtered, err := NewDBRepo(dsn, WithLinkIDFilter(func(id model.LinkID) bool { return id%3 == 0 }))
+	require.NoError(t, err)
+	defer filtered.Close()
+
+	id, err := filtered.SaveOriginalURL(1, "https://google.com")
+	require.NoError(t, err)
+	require.Greater(t, id, model.LegacyMaxLinkID)
+	require.Zero(t, id%3)
+
+	urls, err := filtered.GetOriginalURLsByUserID(1)
+	require.NoError(t, err)
+	require.Len(t, urls, 3)
+}
+
+func TestDBRepo_LinkVariants(t *testing.T) {
+	repo := newTestDBRepo(t)
+	user := newTestUser(repo, t)
+	user.saveOriginalURL("https://landing.example/")
+	linkID := user.links["https://landing.example/"]
+
+	variants := model.Variants{
+		{Name: "a", OriginalURL: "https://landing.example/a", Weight: 70},
+		{Name: "b", OriginalURL: "https://landing.example/b", Weight: 30},
+	}
+	_, err := repo.UpdateUserLink(user.id, linkID, model.LinkPatch{Variants: &variants})
+	require.NoError(t, err)
+
+	for _, variant := range []string{"a", "a", "a", "b"} {
+		require.NoError(t, repo.CountClick(linkID, model.Click{Variant: variant}))
+	}
+
+	// У варианта с прежним именем сохраняются переходы, удаленный вариант пропадает вместе с ними.
+	variants = model.Variants{
+		{Name: "c", OriginalURL: "https://landing.example/c", Weight: 1},
+		{Name: "a", OriginalURL: "https://landing.example/a2", Weight: 1},
+	}
+	link, err := repo.UpdateUserLink(user.id, linkID, model.LinkPatch{Variants: &variants})
+	require.NoError(t, err)
+	require.Equal(t, model.Variants{
+		{Name: "c", OriginalURL: "https://landing.example/c", Weight: 1},
+		{Name: "a", OriginalURL: "https://landing.example/a2", Weight: 1, Clicks: 3},
+	}, link.Variants)
+	require.Equal(t, int64(4), link.Clicks)
+
+	details, err := repo.GetLinkDetails(linkID)
+	require.NoError(t, err)
+	require.Equal(t, link.Variants, details.Variants)
+
+	empty := model.Variants{}
+	link, err = repo.UpdateUserLink(user.id, linkID, model.LinkPatch{Variants: &empty})
+	require.NoError(t, err)
+	require.Empty(t, link.Variants)
+}
+
+func TestDBRepo_CountryRouting(t *testing.T) {
+	repo := newTestDBRepo(t)
+	user := newTestUser(repo, t)
+	user.saveOriginalURL("https://shop.example/")
+	linkID := user.links["https://shop.example/"]
+
+	routing := model.RoutingRules{
+		{Countries: []string{"GB", "IE"}, OriginalURL: "https://shop.example/uk"},
+		{Platforms: []model.Platform{model.PlatformAndroid}, Languages: []string{"en"}, OriginalURL: "https://shop.example/app"},
+		{QueryParam: "beta", OriginalURL: "https://beta.shop.example/"},
+	}
+	link, err := repo.UpdateUserLink(user.id, linkID, model.LinkPatch{Routing: &routing})
+	require.NoError(t, err)
+	require.Equal(t, routing, link.Routing)
+
+	for _, country := range []string{"GB", "GB", "IE", ""} {
+		require.NoError(t, repo.CountClick(linkID, model.Click{Country: country}))
+	}
+
+	details, err := repo.GetLinkDetails(linkID)
+	require.NoError(t, err)
+	require.Equal(t, routing, details.Routing)
+	require.Equal(t, int64(4), details.Clicks)
+	require.Equal(t, map[string]int64{"GB": 2, "IE": 1}, details.CountryClicks)
+
+	var destinations []string
+	require.NoError(t, repo.ForEachLink(func(id model.LinkID, originalURLs []string) error {
+		if id == linkID {
+			destinations = originalURLs
+		}
+		return nil
+	}))
+	require.ElementsMatch(t, []string{
+		"https://shop.example/", "https://shop.example/uk", "https://shop.example/app", "https://beta.shop.example/",
+	}, destinations)
+
+	// Пустой список удаляет правила, статистика по странам сохраняется.
+	empty := model.RoutingRules{}
+	link, err = repo.UpdateUserLink(user.id, linkID, model.LinkPatch{Routing: &empty})
+	require.NoError(t, err)
+	require.Empty(t, link.Routing)
+	require.Equal(t, map[string]int64{"GB": 2, "IE": 1}, link.CountryClicks)
+}
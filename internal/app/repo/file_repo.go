@@ -2,27 +2,42 @@ package repo
 
 import (
 	"errors"
+	"log"
 	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ikashurnikov/shortener/internal/app/model"
 )
 
+// clicksFlushInterval Период записи в файл переходов по ссылкам.
+const clicksFlushInterval = 5 * time.Second
+
 type fileRepo struct {
 	filename string
 	cache    *inMemoryRepo
 	guard    sync.Mutex
+	// unsavedClicks 1, если есть переходы, не записанные в файл. Переходы записываются
+	// периодически, а не при каждом переходе, чтобы перенаправление не переписывало весь файл.
+	unsavedClicks int32
+	stop          chan struct{}
+	wg            sync.WaitGroup
 }
 
-func NewFileRepo(filename string) (*fileRepo, error) {
+func NewFileRepo(filename string, opts ...Option) (*fileRepo, error) {
 	repo := &fileRepo{
 		filename: filename,
-		cache:    NewInMemoryRepo(),
+		cache:    NewInMemoryRepo(opts...),
+		stop:     make(chan struct{}),
 	}
 
 	if err := repo.load(); err != nil {
 		return nil, err
 	}
+
+	repo.wg.Add(1)
+	go repo.flushClicks(clicksFlushInterval)
 	return repo, nil
 }
 
@@ -34,18 +49,24 @@ func (repo *fileRepo) AddUser() (model.UserID, error) {
 	return userID, err
 }
 
+// SaveOriginalURL Сохраняет ссылку. Существующая ссылка тоже записывается в файл: она могла быть
+// добавлена в список пользователя или восстановлена из корзины.
 func (repo *fileRepo) SaveOriginalURL(userID model.UserID, originalURL string) (model.LinkID, error) {
 	linkID, err := repo.cache.SaveOriginalURL(userID, originalURL)
-	if err == nil {
-		err = repo.save()
+	if err == nil || errors.Is(err, model.ErrLinkAlreadyExists) {
+		if saveErr := repo.save(); saveErr != nil {
+			err = saveErr
+		}
 	}
 	return linkID, err
 }
 
 func (repo *fileRepo) SaveOriginalURLs(userID model.UserID, originalURLs []string) ([]model.LinkID, error) {
 	linkIDs, err := repo.cache.SaveOriginalURLs(userID, originalURLs)
-	if err == nil {
-		err = repo.save()
+	if err == nil || errors.Is(err, model.ErrLinkAlreadyExists) {
+		if saveErr := repo.save(); saveErr != nil {
+			err = saveErr
+		}
 	}
 	return linkIDs, err
 }
@@ -54,31 +75,158 @@ func (repo *fileRepo) GetOriginalURLByID(id model.LinkID) (string, error) {
 	return repo.cache.GetOriginalURLByID(id)
 }
 
+func (repo *fileRepo) GetLinkAuthor(id model.LinkID) (model.UserID, error) {
+	return repo.cache.GetLinkAuthor(id)
+}
+
+func (repo *fileRepo) GetLinkDetails(id model.LinkID) (model.LinkDetails, error) {
+	return repo.cache.GetLinkDetails(id)
+}
+
+func (repo *fileRepo) CountClick(id model.LinkID, click model.Click) error {
+	err := repo.cache.CountClick(id, click)
+	if err == nil {
+		atomic.StoreInt32(&repo.unsavedClicks, 1)
+	}
+	return err
+}
+
+func (repo *fileRepo) ForEachLink(fn func(id model.LinkID, originalURLs []string) error) error {
+	return repo.cache.ForEachLink(fn)
+}
+
 func (repo *fileRepo) GetOriginalURLsByUserID(id model.UserID) (map[string]model.LinkID, error) {
 	return repo.cache.GetOriginalURLsByUserID(id)
 }
 
+func (repo *fileRepo) GetUserLinks(id model.UserID, query model.UserLinksQuery) ([]model.UserLink, error) {
+	return repo.cache.GetUserLinks(id, query)
+}
+
+func (repo *fileRepo) UpdateUserLink(userID model.UserID, linkID model.LinkID, patch model.LinkPatch) (model.UserLink, error) {
+	link, err := repo.cache.UpdateUserLink(userID, linkID, patch)
+	if err == nil {
+		err = repo.save()
+	}
+	return link, err
+}
+
+func (repo *fileRepo) RetargetLink(userID model.UserID, linkID model.LinkID, originalURL string) (model.UserLink, error) {
+	link, err := repo.cache.RetargetLink(userID, linkID, originalURL)
+	if err == nil {
+		err = repo.save()
+	}
+	return link, err
+}
+
+func (repo *fileRepo) UpdateLinkSettings(userID model.UserID, linkID model.LinkID, patch model.LinkPatch) (model.UserLink, error) {
+	link, err := repo.cache.UpdateLinkSettings(userID, linkID, patch)
+	if err == nil {
+		err = repo.save()
+	}
+	return link, err
+}
+
+func (repo *fileRepo) GetLinkHistory(userID model.UserID, linkID model.LinkID) ([]model.LinkVersion, error) {
+	return repo.cache.GetLinkHistory(userID, linkID)
+}
+
 func (repo *fileRepo) DeleteURLs(userID model.UserID, links []model.LinkID) error {
 	err := repo.cache.DeleteURLs(userID, links)
-	if err != nil {
+	if err == nil {
+		err = repo.save()
+	}
+	return err
+}
+
+func (repo *fileRepo) SetLinkDisabled(linkID model.LinkID, disabled bool) error {
+	err := repo.cache.SetLinkDisabled(linkID, disabled)
+	if err == nil {
+		err = repo.save()
+	}
+	return err
+}
+
+func (repo *fileRepo) AddReport(linkID model.LinkID, reason string) (model.LinkReport, error) {
+	report, err := repo.cache.AddReport(linkID, reason)
+	if err == nil {
+		err = repo.save()
+	}
+	return report, err
+}
+
+func (repo *fileRepo) GetReports() ([]model.LinkReport, error) {
+	return repo.cache.GetReports()
+}
+
+func (repo *fileRepo) RestoreURLs(userID model.UserID, links []model.LinkID) error {
+	err := repo.cache.RestoreURLs(userID, links)
+	if err == nil {
 		err = repo.save()
 	}
 	return err
 }
 
+func (repo *fileRepo) PurgeDeletedURLs(deletedBefore time.Time) (int, error) {
+	count, err := repo.cache.PurgeDeletedURLs(deletedBefore)
+	if err == nil && count != 0 {
+		err = repo.save()
+	}
+	return count, err
+}
+
 func (repo *fileRepo) Ping() error {
 	return nil
 }
 
+// Close Останавливает запись переходов и записывает в файл оставшиеся.
 func (repo *fileRepo) Close() error {
+	close(repo.stop)
+	repo.wg.Wait()
+
+	if atomic.LoadInt32(&repo.unsavedClicks) != 0 {
+		return repo.save()
+	}
 	return nil
 }
 
+// flushClicks Записывает переходы в файл с периодом interval до остановки хранилища.
+func (repo *fileRepo) flushClicks(interval time.Duration) {
+	defer repo.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if atomic.LoadInt32(&repo.unsavedClicks) == 0 {
+				continue
+			}
+			if err := repo.save(); err != nil {
+				log.Printf("saving clicks failed: %v", err)
+			}
+		case <-repo.stop:
+			return
+		}
+	}
+}
+
+// save Записывает в файл все данные, в том числе еще не записанные переходы.
 func (repo *fileRepo) save() error {
 	repo.guard.Lock()
 	defer repo.guard.Unlock()
 
-	file, err := os.OpenFile(repo.filename, os.O_WRONLY|os.O_CREATE, 0664)
+	atomic.StoreInt32(&repo.unsavedClicks, 0)
+	if err := repo.write(); err != nil {
+		atomic.StoreInt32(&repo.unsavedClicks, 1)
+		return err
+	}
+	return nil
+}
+
+func (repo *fileRepo) write() error {
+	file, err := os.OpenFile(repo.filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0664)
 	if err != nil {
 		return err
 	}
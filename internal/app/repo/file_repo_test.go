@@ -3,6 +3,7 @@ package repo
 import (
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/ikashurnikov/shortener/internal/app/model"
 	"github.com/stretchr/testify/require"
 	"os"
 	"testing"
@@ -16,10 +17,10 @@ func TestFileRepo(t *testing.T) {
 		}
 	}()
 
-	testStorage(func() Repo {
+	testStorage(func(opts ...Option) Repo {
 		filename := uuid.New().String()
 		filenames = append(filenames, filename)
-		storage, err := NewFileRepo(filename)
+		storage, err := NewFileRepo(filename, opts...)
 		require.NoError(t, err)
 		return storage
 	}, t)
@@ -55,3 +56,126 @@ func TestFileStorage_ReadWrite(t *testing.T) {
 	require.NoError(t, err)
 	require.True(t, user2.equal(urls))
 }
+
+func TestFileStorage_LegacyFormat(t *testing.T) {
+	filename := uuid.New().String()
+	defer os.Remove(filename)
+
+	legacy := `{"items":[{"original_uRL":"https://yandex.ru","users":{"0":false,"1":true}}],"next_user_id":2}`
+	require.NoError(t, os.WriteFile(filename, []byte(legacy), 0664))
+
+	repo, err := NewFileRepo(filename)
+	require.NoError(t, err)
+
+	urls, err := repo.GetOriginalURLsByUserID(0)
+	require.NoError(t, err)
+	require.Equal(t, map[string]model.LinkID{"https://yandex.ru": 0}, urls)
+
+	urls, err = repo.GetOriginalURLsByUserID(1)
+	require.NoError(t, err)
+	require.Empty(t, urls)
+
+	trash, err := repo.GetUserLinks(1, model.UserLinksQuery{Trash: true})
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	require.NotNil(t, trash[0].DeletedAt)
+	require.False(t, trash[0].DeletedAt.IsZero())
+
+	history, err := repo.GetLinkHistory(0, 0)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, "https://yandex.ru", history[0].OriginalURL)
+
+	id, err := repo.SaveOriginalURL(0, "https://yandex.ru")
+	require.ErrorIs(t, err, model.ErrLinkAlreadyExists)
+	require.Equal(t, model.LinkID(0), id)
+}
+
+func TestFileStorage_SaveClicksOnClose(t *testing.T) {
+	filename := uuid.New().String()
+	defer os.Remove(filename)
+
+	repo, err := NewFileRepo(filename)
+	require.NoError(t, err)
+
+	user := newTestUser(repo, t)
+	user.saveOriginalURL("https://yandex.ru")
+	linkID := user.links["https://yandex.ru"]
+	require.NoError(t, repo.CountClick(linkID, model.Click{Country: "RU"}))
+	require.NoError(t, repo.CountClick(linkID, model.Click{}))
+	require.NoError(t, repo.Close())
+
+	repo, err = NewFileRepo(filename)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	details, err := repo.GetLinkDetails(linkID)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), details.Clicks)
+	require.Equal(t, map[string]int64{"RU": 1}, details.CountryClicks)
+}
+
+func TestFileStorage_SaveExistingLink(t *testing.T) {
+	filename := uuid.New().String()
+	defer os.Remove(filename)
+
+	repo, err := NewFileRepo(filename)
+	require.NoError(t, err)
+
+	user1 := newTestUser(repo, t)
+	user2 := newTestUser(repo, t)
+	user1.saveOriginalURL("https://yandex.ru")
+	user1.saveOriginalURL("https://google.com")
+	linkID := user1.links["https://google.com"]
+	require.NoError(t, repo.DeleteURLs(user1.id, []model.LinkID{linkID}))
+
+	// Ссылка добавляется в список другого пользователя и восстанавливается из корзины.
+	_, err = repo.SaveOriginalURL(user2.id, "https://yandex.ru")
+	require.ErrorIs(t, err, model.ErrLinkAlreadyExists)
+	_, err = repo.SaveOriginalURL(user1.id, "https://google.com")
+	require.ErrorIs(t, err, model.ErrLinkAlreadyExists)
+	require.NoError(t, repo.Close())
+
+	repo, err = NewFileRepo(filename)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	urls, err := repo.GetOriginalURLsByUserID(user1.id)
+	require.NoError(t, err)
+	require.True(t, user1.equal(urls))
+
+	urls, err = repo.GetOriginalURLsByUserID(user2.id)
+	require.NoError(t, err)
+	require.Equal(t, map[string]model.LinkID{"https://yandex.ru": user1.links["https://yandex.ru"]}, urls)
+}
+
+func TestFileStorage_SaveExistingLinkPerUser(t *testing.T) {
+	filename := uuid.New().String()
+	defer os.Remove(filename)
+
+	repo, err := NewFileRepo(filename, WithDedupMode(model.DedupPerUser))
+	require.NoError(t, err)
+
+	user1 := newTestUser(repo, t)
+	user2 := newTestUser(repo, t)
+	user1.saveOriginalURL("https://yandex.ru")
+	user2.saveOriginalURL("https://yandex.ru")
+	require.NotEqual(t, user1.links["https://yandex.ru"], user2.links["https://yandex.ru"])
+	require.NoError(t, repo.DeleteURLs(user2.id, []model.LinkID{user2.links["https://yandex.ru"]}))
+
+	// Пользователь восстанавливает свою ссылку из корзины, сокращая ее повторно.
+	id, err := repo.SaveOriginalURL(user2.id, "https://yandex.ru")
+	require.ErrorIs(t, err, model.ErrLinkAlreadyExists)
+	require.Equal(t, user2.links["https://yandex.ru"], id)
+	require.NoError(t, repo.Close())
+
+	repo, err = NewFileRepo(filename, WithDedupMode(model.DedupPerUser))
+	require.NoError(t, err)
+	defer repo.Close()
+
+	for _, user := range []testUser{user1, user2} {
+		urls, err := repo.GetOriginalURLsByUserID(user.id)
+		require.NoError(t, err)
+		require.True(t, user.equal(urls))
+	}
+}
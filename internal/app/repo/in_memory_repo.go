@@ -3,30 +3,129 @@ package repo
 import (
 	"encoding/json"
 	"errors"
+	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/ikashurnikov/shortener/internal/app/model"
 )
 
 type (
-	linkDeleted bool
+	// userLink Ссылка пользователя и ее метаданные.
+	userLink struct {
+		Deleted   bool      `json:"deleted"`
+		DeletedAt time.Time `json:"deleted_at,omitempty"`
+		CreatedAt time.Time `json:"created_at"`
+		Title     string    `json:"title,omitempty"`
+		Note      string    `json:"note,omitempty"`
+		Tags      []string  `json:"tags,omitempty"`
+	}
+
+	// linkVersion Версия оригинальной ссылки.
+	linkVersion struct {
+		OriginalURL string       `json:"original_url"`
+		CreatedAt   time.Time    `json:"created_at"`
+		Author      model.UserID `json:"author"`
+	}
 
 	item struct {
-		OriginalURL string                       `json:"original_uRL"`
-		Users       map[model.UserID]linkDeleted `json:"users"`
+		OriginalURL string `json:"original_uRL"`
+		// DedupKey Ключ дедупликации. Пустая строка, если ссылка не участвует в дедупликации.
+		DedupKey string                     `json:"dedup_key"`
+		History  []linkVersion              `json:"history"`
+		Users    map[model.UserID]*userLink `json:"users"`
+		// Purged Ссылка окончательно удалена из корзин всех пользователей.
+		Purged bool `json:"purged,omitempty"`
+		// Disabled Ссылка отключена модератором.
+		Disabled bool `json:"disabled,omitempty"`
+		// Clicks Кол-во переходов по ссылке.
+		Clicks int64 `json:"clicks,omitempty"`
+		// CountryClicks Кол-во переходов по ISO-кодам стран.
+		CountryClicks map[string]int64 `json:"country_clicks,omitempty"`
+		// Interstitial Показывать страницу предпросмотра перед переходом.
+		Interstitial bool `json:"interstitial,omitempty"`
+		// RedirectStatus HTTP статус перенаправления. 0 - статус по умолчанию.
+		RedirectStatus int `json:"redirect_status,omitempty"`
+		// Routing Правила выбора оригинальной ссылки при переходе.
+		Routing model.RoutingRules `json:"routing,omitempty"`
+		// Variants Варианты оригинальной ссылки вместе с кол-вом переходов на них.
+		Variants model.Variants `json:"variants,omitempty"`
+	}
+
+	// report Жалоба на ссылку. ID жалобы - ее индекс в Reports, увеличенный на 1.
+	report struct {
+		LinkID    model.LinkID `json:"link_id"`
+		Reason    string       `json:"reason"`
+		CreatedAt time.Time    `json:"created_at"`
 	}
 
 	inMemoryRepo struct {
 		Items      []*item      `json:"items"`
 		NextUserID model.UserID `json:"next_user_id"`
+		Reports    []report     `json:"reports,omitempty"`
 		guard      sync.RWMutex
+		opts       options
 	}
 )
 
-func NewInMemoryRepo() *inMemoryRepo {
-	return &inMemoryRepo{}
+func NewInMemoryRepo(opts ...Option) *inMemoryRepo {
+	return &inMemoryRepo{opts: newOptions(opts)}
+}
+
+// UnmarshalJSON Поддерживает старый формат файла, в котором для пользователя хранился только признак удаления.
+func (l *userLink) UnmarshalJSON(data []byte) error {
+	var deleted bool
+	if err := json.Unmarshal(data, &deleted); err == nil {
+		*l = userLink{Deleted: deleted}
+		return nil
+	}
+
+	type plainUserLink userLink
+	return json.Unmarshal(data, (*plainUserLink)(l))
+}
+
+func (l *userLink) toModel(linkID model.LinkID, userID model.UserID, it *item) model.UserLink {
+	res := model.UserLink{
+		ID:             linkID,
+		UserID:         userID,
+		OriginalURL:    it.OriginalURL,
+		Clicks:         it.Clicks,
+		CountryClicks:  cloneCountryClicks(it.CountryClicks),
+		Interstitial:   it.Interstitial,
+		RedirectStatus: it.RedirectStatus,
+		Routing:        it.Routing,
+		Variants:       slices.Clone(it.Variants),
+		CreatedAt:      l.CreatedAt,
+		Title:          l.Title,
+		Note:           l.Note,
+		Tags:           l.Tags,
+	}
+	if l.Deleted {
+		deletedAt := l.DeletedAt
+		res.DeletedAt = &deletedAt
+	}
+	return res
+}
+
+func (r *report) toModel(id int64, it *item) model.LinkReport {
+	return model.LinkReport{
+		ID:          id,
+		LinkID:      r.LinkID,
+		Reason:      r.Reason,
+		CreatedAt:   r.CreatedAt,
+		OriginalURL: it.OriginalURL,
+		Disabled:    it.Disabled,
+	}
+}
+
+func (l *userLink) setDeleted(deleted bool) {
+	l.Deleted = deleted
+	l.DeletedAt = time.Time{}
+	if deleted {
+		l.DeletedAt = time.Now()
+	}
 }
 
 func (repo *inMemoryRepo) Serialize(w io.Writer) error {
@@ -40,7 +139,24 @@ func (repo *inMemoryRepo) Serialize(w io.Writer) error {
 
 func (repo *inMemoryRepo) Deserialize(r io.Reader) error {
 	dec := json.NewDecoder(r)
-	return dec.Decode(repo)
+	if err := dec.Decode(repo); err != nil {
+		return err
+	}
+
+	// В старом формате файла не было истории, ключом дедупликации служила сама ссылка,
+	// а время удаления ссылок не сохранялось.
+	for _, it := range repo.Items {
+		if len(it.History) == 0 && !it.Purged {
+			it.DedupKey = it.OriginalURL
+			it.History = []linkVersion{{OriginalURL: it.OriginalURL, Author: it.firstUser()}}
+		}
+		for _, link := range it.Users {
+			if link.Deleted && link.DeletedAt.IsZero() {
+				link.setDeleted(true)
+			}
+		}
+	}
+	return nil
 }
 
 func (repo *inMemoryRepo) AddUser() (model.UserID, error) {
@@ -78,13 +194,19 @@ func (repo *inMemoryRepo) GetOriginalURLByID(id model.LinkID) (string, error) {
 	repo.guard.RLock()
 	defer repo.guard.RUnlock()
 
-	if int(id) >= len(repo.Items) {
+	if id >= model.LinkID(len(repo.Items)) {
 		return "", model.ErrLinkNotFound
 	}
 
 	it := repo.Items[id]
-	for _, deleted := range it.Users {
-		if !deleted {
+	if it.Purged {
+		return "", model.ErrLinkNotFound
+	}
+	if it.Disabled {
+		return it.OriginalURL, model.ErrLinkDisabled
+	}
+	for _, link := range it.Users {
+		if !link.Deleted {
 			return it.OriginalURL, nil
 		}
 	}
@@ -92,6 +214,79 @@ func (repo *inMemoryRepo) GetOriginalURLByID(id model.LinkID) (string, error) {
 	return it.OriginalURL, model.ErrLinkRemoved
 }
 
+func (repo *inMemoryRepo) GetLinkAuthor(id model.LinkID) (model.UserID, error) {
+	repo.guard.RLock()
+	defer repo.guard.RUnlock()
+
+	if id >= model.LinkID(len(repo.Items)) || repo.Items[id].Purged {
+		return model.InvalidUserID, model.ErrLinkNotFound
+	}
+
+	return repo.Items[id].author(), nil
+}
+
+func (repo *inMemoryRepo) GetLinkDetails(id model.LinkID) (model.LinkDetails, error) {
+	repo.guard.RLock()
+	defer repo.guard.RUnlock()
+
+	if id >= model.LinkID(len(repo.Items)) || repo.Items[id].Purged {
+		return model.LinkDetails{}, model.ErrLinkNotFound
+	}
+
+	it := repo.Items[id]
+	details := model.LinkDetails{
+		Clicks:         it.Clicks,
+		CountryClicks:  cloneCountryClicks(it.CountryClicks),
+		Interstitial:   it.Interstitial,
+		RedirectStatus: it.RedirectStatus,
+		Routing:        it.Routing,
+		Variants:       slices.Clone(it.Variants),
+	}
+	if link, ok := it.Users[it.author()]; ok && !link.Deleted {
+		details.Title, details.Note = link.Title, link.Note
+	}
+	return details, nil
+}
+
+func (repo *inMemoryRepo) CountClick(id model.LinkID, click model.Click) error {
+	repo.guard.Lock()
+	defer repo.guard.Unlock()
+
+	if id >= model.LinkID(len(repo.Items)) || repo.Items[id].Purged {
+		return model.ErrLinkNotFound
+	}
+
+	it := repo.Items[id]
+	it.Clicks++
+	if click.Variant != "" {
+		if idx := slices.IndexFunc(it.Variants, func(v model.Variant) bool { return v.Name == click.Variant }); idx >= 0 {
+			it.Variants[idx].Clicks++
+		}
+	}
+	if click.Country != "" {
+		if it.CountryClicks == nil {
+			it.CountryClicks = make(map[string]int64)
+		}
+		it.CountryClicks[click.Country]++
+	}
+	return nil
+}
+
+func (repo *inMemoryRepo) ForEachLink(fn func(id model.LinkID, originalURLs []string) error) error {
+	repo.guard.RLock()
+	defer repo.guard.RUnlock()
+
+	for id, it := range repo.Items {
+		if it.Purged {
+			continue
+		}
+		if err := fn(model.LinkID(id), model.LinkDestinations(it.OriginalURL, it.Routing, it.Variants)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (repo *inMemoryRepo) GetOriginalURLsByUserID(userID model.UserID) (map[string]model.LinkID, error) {
 	repo.guard.RLock()
 	defer repo.guard.RUnlock()
@@ -102,14 +297,54 @@ func (repo *inMemoryRepo) GetOriginalURLsByUserID(userID model.UserID) (map[stri
 
 	res := make(map[string]model.LinkID)
 	for idx, it := range repo.Items {
-		deleted, ok := it.Users[userID]
-		if ok && !bool(deleted) {
+		link, ok := it.Users[userID]
+		if ok && !link.Deleted {
 			res[it.OriginalURL] = model.LinkID(idx)
 		}
 	}
 	return res, nil
 }
 
+func (repo *inMemoryRepo) GetUserLinks(userID model.UserID, query model.UserLinksQuery) ([]model.UserLink, error) {
+	repo.guard.RLock()
+	defer repo.guard.RUnlock()
+
+	if !repo.IsValidUserID(userID) {
+		return nil, model.ErrUserNotFound
+	}
+
+	res := make([]model.UserLink, 0)
+	count := len(repo.Items)
+	for i := 0; i < count; i++ {
+		idx := i
+		if query.Order == model.SortDesc {
+			idx = count - 1 - i
+		}
+
+		linkID := model.LinkID(idx)
+		if !query.Follows(linkID) {
+			continue
+		}
+
+		it := repo.Items[idx]
+		userLink, ok := it.Users[userID]
+		if !ok || userLink.Deleted != query.Trash {
+			continue
+		}
+
+		link := userLink.toModel(linkID, userID, it)
+		if !query.Match(link) {
+			continue
+		}
+
+		res = append(res, link)
+		if query.Limit > 0 && len(res) == query.Limit {
+			break
+		}
+	}
+	return res, nil
+}
+
 func (repo *inMemoryRepo) DeleteURLs(userID model.UserID, links []model.LinkID) error {
 	repo.guard.Lock()
 	defer repo.guard.Unlock()
@@ -119,18 +354,214 @@ func (repo *inMemoryRepo) DeleteURLs(userID model.UserID, links []model.LinkID)
 	}
 
 	for _, linkID := range links {
-		if int(linkID) >= len(repo.Items) {
+		if linkID >= model.LinkID(len(repo.Items)) {
 			continue
 		}
 		it := repo.Items[linkID]
-		_, ok := it.Users[userID]
-		if ok {
-			it.Users[userID] = true
+		link, ok := it.Users[userID]
+		if ok && !link.Deleted {
+			link.setDeleted(true)
 		}
 	}
 	return nil
 }
 
+func (repo *inMemoryRepo) RestoreURLs(userID model.UserID, links []model.LinkID) error {
+	repo.guard.Lock()
+	defer repo.guard.Unlock()
+
+	if !repo.IsValidUserID(userID) {
+		return model.ErrUserNotFound
+	}
+
+	for _, linkID := range links {
+		if linkID >= model.LinkID(len(repo.Items)) {
+			continue
+		}
+		link, ok := repo.Items[linkID].Users[userID]
+		if ok && link.Deleted {
+			link.setDeleted(false)
+		}
+	}
+	return nil
+}
+
+func (repo *inMemoryRepo) SetLinkDisabled(linkID model.LinkID, disabled bool) error {
+	repo.guard.Lock()
+	defer repo.guard.Unlock()
+
+	if linkID >= model.LinkID(len(repo.Items)) || repo.Items[linkID].Purged {
+		return model.ErrLinkNotFound
+	}
+	repo.Items[linkID].Disabled = disabled
+	return nil
+}
+
+func (repo *inMemoryRepo) AddReport(linkID model.LinkID, reason string) (model.LinkReport, error) {
+	repo.guard.Lock()
+	defer repo.guard.Unlock()
+
+	if linkID >= model.LinkID(len(repo.Items)) || repo.Items[linkID].Purged {
+		return model.LinkReport{}, model.ErrLinkNotFound
+	}
+
+	r := report{LinkID: linkID, Reason: reason, CreatedAt: time.Now()}
+	repo.Reports = append(repo.Reports, r)
+	return r.toModel(int64(len(repo.Reports)), repo.Items[linkID]), nil
+}
+
+func (repo *inMemoryRepo) GetReports() ([]model.LinkReport, error) {
+	repo.guard.RLock()
+	defer repo.guard.RUnlock()
+
+	res := make([]model.LinkReport, 0, len(repo.Reports))
+	for i, r := range repo.Reports {
+		if it := repo.Items[r.LinkID]; !it.Purged {
+			res = append(res, r.toModel(int64(i+1), it))
+		}
+	}
+	return res, nil
+}
+
+func (repo *inMemoryRepo) PurgeDeletedURLs(deletedBefore time.Time) (int, error) {
+	repo.guard.Lock()
+	defer repo.guard.Unlock()
+
+	count := 0
+	for _, it := range repo.Items {
+		if it.Purged {
+			continue
+		}
+
+		for userID, link := range it.Users {
+			if link.Deleted && link.DeletedAt.Before(deletedBefore) {
+				delete(it.Users, userID)
+				count++
+			}
+		}
+
+		// ID ссылки - индекс в Items, поэтому вместо удаления элемента очищаем его.
+		if len(it.Users) == 0 {
+			*it = item{Purged: true}
+		}
+	}
+	return count, nil
+}
+
+func (repo *inMemoryRepo) UpdateUserLink(userID model.UserID, linkID model.LinkID, patch model.LinkPatch) (model.UserLink, error) {
+	repo.guard.Lock()
+	defer repo.guard.Unlock()
+
+	it, link, err := repo.getUserLink(userID, linkID)
+	if err != nil {
+		return model.UserLink{}, err
+	}
+
+	// Проверки выполняются до изменений, чтобы изменение не применилось частично.
+	if (patch.OriginalURL != nil || patch.HasLinkSettings()) && len(it.Users) != 1 {
+		return model.UserLink{}, model.ErrLinkShared
+	}
+
+	if patch.OriginalURL != nil {
+		it.retarget(userID, *patch.OriginalURL)
+	}
+	it.applyLinkSettings(patch)
+
+	res := link.toModel(linkID, userID, it)
+	patch.Apply(&res)
+	link.Title, link.Note, link.Tags = res.Title, res.Note, res.Tags
+	return res, nil
+}
+
+func (repo *inMemoryRepo) RetargetLink(userID model.UserID, linkID model.LinkID, originalURL string) (model.UserLink, error) {
+	repo.guard.Lock()
+	defer repo.guard.Unlock()
+
+	it, link, err := repo.getUserLink(userID, linkID)
+	if err != nil {
+		return model.UserLink{}, err
+	}
+
+	if len(it.Users) != 1 {
+		return model.UserLink{}, model.ErrLinkShared
+	}
+
+	it.retarget(userID, originalURL)
+	return link.toModel(linkID, userID, it), nil
+}
+
+func (repo *inMemoryRepo) UpdateLinkSettings(userID model.UserID, linkID model.LinkID, patch model.LinkPatch) (model.UserLink, error) {
+	repo.guard.Lock()
+	defer repo.guard.Unlock()
+
+	it, link, err := repo.getUserLink(userID, linkID)
+	if err != nil {
+		return model.UserLink{}, err
+	}
+
+	if len(it.Users) != 1 {
+		return model.UserLink{}, model.ErrLinkShared
+	}
+
+	it.applyLinkSettings(patch)
+	return link.toModel(linkID, userID, it), nil
+}
+
+// retarget Изменяет оригинальную ссылку и добавляет новую версию в историю.
+func (it *item) retarget(userID model.UserID, originalURL string) {
+	if it.OriginalURL == originalURL {
+		return
+	}
+
+	it.OriginalURL = originalURL
+	it.DedupKey = ""
+	it.History = append(it.History, linkVersion{
+		OriginalURL: originalURL,
+		CreatedAt:   time.Now(),
+		Author:      userID,
+	})
+}
+
+// applyLinkSettings Применяет изменения общих настроек ссылки (см. model.LinkPatch.HasLinkSettings).
+func (it *item) applyLinkSettings(patch model.LinkPatch) {
+	if patch.Interstitial != nil {
+		it.Interstitial = *patch.Interstitial
+	}
+	if patch.RedirectStatus != nil {
+		it.RedirectStatus = *patch.RedirectStatus
+	}
+	if patch.Routing != nil {
+		it.Routing = nil
+		if len(*patch.Routing) > 0 {
+			it.Routing = slices.Clone(*patch.Routing)
+		}
+	}
+	if patch.Variants != nil {
+		it.Variants = mergeVariants(it.Variants, *patch.Variants)
+	}
+}
+
+func (repo *inMemoryRepo) GetLinkHistory(userID model.UserID, linkID model.LinkID) ([]model.LinkVersion, error) {
+	repo.guard.RLock()
+	defer repo.guard.RUnlock()
+
+	it, _, err := repo.getUserLink(userID, linkID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]model.LinkVersion, len(it.History))
+	for i, version := range it.History {
+		res[i] = model.LinkVersion{
+			Version:     i + 1,
+			OriginalURL: version.OriginalURL,
+			CreatedAt:   version.CreatedAt,
+			Author:      version.Author,
+		}
+	}
+	return res, nil
+}
+
 func (repo *inMemoryRepo) IsValidUserID(id model.UserID) bool {
 	return id.IsValid() && id < repo.NextUserID
 }
@@ -142,16 +573,27 @@ func (repo *inMemoryRepo) saveOriginalURL(userID model.UserID, originalURL strin
 
 	var err error
 
-	idx := slices.IndexFunc(repo.Items, func(i *item) bool { return i.OriginalURL == originalURL })
+	idx := -1
+	dedupKey := repo.opts.dedupMode.DedupKey(userID, originalURL)
+	if dedupKey != "" {
+		idx = slices.IndexFunc(repo.Items, func(i *item) bool { return i.DedupKey == dedupKey })
+	}
+
 	if idx == -1 {
-		repo.addItem(originalURL)
+		if err := repo.addItem(userID, originalURL, dedupKey); err != nil {
+			return 0, err
+		}
 		idx = len(repo.Items) - 1
 	} else {
 		err = model.ErrLinkAlreadyExists
 	}
 
 	it := repo.Items[idx]
-	it.Users[userID] = false
+	if link, ok := it.Users[userID]; ok {
+		link.setDeleted(false)
+	} else {
+		it.Users[userID] = &userLink{CreatedAt: time.Now()}
+	}
 	return model.LinkID(idx), err
 }
 
@@ -163,10 +605,78 @@ func (repo *inMemoryRepo) Close() error {
 	return nil
 }
 
-func (repo *inMemoryRepo) addItem(url string) {
+func (repo *inMemoryRepo) addItem(userID model.UserID, url string, dedupKey string) error {
+	// ID ссылки - индекс в Items, поэтому пропущенные ID занимаем удаленными элементами.
+	for skipped := 0; !repo.opts.acceptLinkID(model.LinkID(len(repo.Items))); skipped++ {
+		if skipped == maxSkippedLinkIDs {
+			return model.ErrNoFreeLinkID
+		}
+		repo.Items = append(repo.Items, &item{Purged: true})
+	}
+
 	i := &item{
 		OriginalURL: url,
-		Users:       make(map[model.UserID]linkDeleted),
+		DedupKey:    dedupKey,
+		History:     []linkVersion{{OriginalURL: url, CreatedAt: time.Now(), Author: userID}},
+		Users:       make(map[model.UserID]*userLink),
 	}
 	repo.Items = append(repo.Items, i)
+	return nil
+}
+
+// getUserLink Возвращает неудаленную ссылку пользователя.
+func (repo *inMemoryRepo) getUserLink(userID model.UserID, linkID model.LinkID) (*item, *userLink, error) {
+	if linkID >= model.LinkID(len(repo.Items)) {
+		return nil, nil, model.ErrLinkNotFound
+	}
+
+	it := repo.Items[linkID]
+	link, ok := it.Users[userID]
+	if !ok || link.Deleted {
+		return nil, nil, model.ErrLinkNotFound
+	}
+	return it, link, nil
+}
+
+// firstUser Возвращает пользователя с наименьшим ID, сохранившего ссылку.
+func (it *item) firstUser() model.UserID {
+	res := model.UserID(model.InvalidUserID)
+	for userID := range it.Users {
+		if !res.IsValid() || userID < res {
+			res = userID
+		}
+	}
+	return res
+}
+
+// author Возвращает пользователя, первым сократившего ссылку.
+func (it *item) author() model.UserID {
+	if len(it.History) == 0 {
+		return it.firstUser()
+	}
+	return it.History[0].Author
+}
+
+// mergeVariants Возвращает новые варианты ссылки с кол-вом переходов прежних вариантов с теми же именами.
+func mergeVariants(old model.Variants, variants model.Variants) model.Variants {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	res := slices.Clone(variants)
+	for i := range res {
+		res[i].Clicks = 0
+		if idx := slices.IndexFunc(old, func(v model.Variant) bool { return v.Name == res[i].Name }); idx >= 0 {
+			res[i].Clicks = old[idx].Clicks
+		}
+	}
+	return res
+}
+
+// cloneCountryClicks Копирует счетчики переходов по странам. Возвращает nil, если переходов нет.
+func cloneCountryClicks(clicks map[string]int64) map[string]int64 {
+	if len(clicks) == 0 {
+		return nil
+	}
+	return maps.Clone(clicks)
 }
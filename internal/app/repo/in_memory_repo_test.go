@@ -3,7 +3,7 @@ package repo
 import "testing"
 
 func TestInMemoryRepo(t *testing.T) {
-	testStorage(func() Repo {
-		return NewInMemoryRepo()
+	testStorage(func(opts ...Option) Repo {
+		return NewInMemoryRepo(opts...)
 	}, t)
 }
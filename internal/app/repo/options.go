@@ -0,0 +1,42 @@
+package repo
+
+import "github.com/ikashurnikov/shortener/internal/app/model"
+
+// Option Настройка хранилища.
+type Option func(*options)
+
+type options struct {
+	dedupMode    model.DedupMode
+	linkIDFilter func(model.LinkID) bool
+}
+
+// WithDedupMode Задает режим дедупликации оригинальных ссылок. По умолчанию model.DedupGlobal.
+func WithDedupMode(mode model.DedupMode) Option {
+	return func(o *options) {
+		o.dedupMode = mode
+	}
+}
+
+// WithLinkIDFilter Задает фильтр ID новых ссылок. ID, для которых filter возвращает false, пропускаются.
+// По умолчанию подходит любой ID.
+func WithLinkIDFilter(filter func(model.LinkID) bool) Option {
+	return func(o *options) {
+		o.linkIDFilter = filter
+	}
+}
+
+func newOptions(opts []Option) options {
+	res := options{dedupMode: model.DedupGlobal}
+	for _, opt := range opts {
+		opt(&res)
+	}
+	return res
+}
+
+// maxSkippedLinkIDs Максимальное кол-во ID, пропускаемых подряд при добавлении ссылки.
+const maxSkippedLinkIDs = 1000
+
+// acceptLinkID Проверяет, может ли ID быть выдан новой ссылке.
+func (o *options) acceptLinkID(linkID model.LinkID) bool {
+	return o.linkIDFilter == nil || o.linkIDFilter(linkID)
+}
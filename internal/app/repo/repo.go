@@ -1,6 +1,10 @@
 package repo
 
-import "github.com/ikashurnikov/shortener/internal/app/model"
+import (
+	"time"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+)
 
 type Repo interface {
 	// AddUser Добавляет нового пользователя.
@@ -8,20 +12,81 @@ type Repo interface {
 
 	//SaveOriginalURL  Сохраняет ссылку и возвращает ее ID.
 	//Если сыылка уже была добавлена, возвращает так же ошибка ErrLinkAlreadyExists.
+	//Когда ссылка считается добавленной, определяет режим дедупликации (см. model.DedupMode).
 	SaveOriginalURL(userID model.UserID, originalURL string) (model.LinkID, error)
 
 	// SaveOriginalURLs Сохраняет ссылки и возвращает их ID
 	SaveOriginalURLs(userID model.UserID, originalURLs []string) ([]model.LinkID, error)
 
-	// GetOriginalURLByID Возвращает ссылку по ее ID
+	// GetOriginalURLByID Возвращает ссылку по ее ID.
+	// Для ссылки, отключенной модератором, возвращает так же ошибку ErrLinkDisabled,
+	// для ссылки, удаленной всеми пользователями, - ErrLinkRemoved.
 	GetOriginalURLByID(id model.LinkID) (string, error)
 
+	// GetLinkAuthor Возвращает пользователя, первым сократившего ссылку.
+	GetLinkAuthor(id model.LinkID) (model.UserID, error)
+
+	// GetLinkDetails Возвращает общие для всех пользователей сведения о ссылке: заголовок и описание ее автора,
+	// кол-во переходов и необходимость страницы предпросмотра.
+	GetLinkDetails(id model.LinkID) (model.LinkDetails, error)
+
+	// CountClick Увеличивает счетчик переходов по ссылке, а также по варианту и стране перехода, если они заданы.
+	// Переход на несуществующий вариант учитывается только в счетчике ссылки.
+	CountClick(id model.LinkID, click model.Click) error
+
+	// ForEachLink Вызывает fn для каждой сохраненной ссылки в порядке ID, пока fn не вернет ошибку.
+	// originalURLs - все оригинальные ссылки короткой ссылки (см. model.LinkDestinations).
+	// Окончательно удаленные ссылки пропускаются. fn не должна обращаться к хранилищу.
+	ForEachLink(fn func(id model.LinkID, originalURLs []string) error) error
+
 	// GetOriginalURLsByUserID возвращает ссылки и их ID, привязанные к пользователю.
 	// Если пользователя не существует, возвращает пустую карту
 	GetOriginalURLsByUserID(id model.UserID) (map[string]model.LinkID, error)
 
+	// GetUserLinks Возвращает страницу ссылок пользователя, отсортированных по ID,
+	// с учетом курсора и фильтров запроса.
+	GetUserLinks(id model.UserID, query model.UserLinksQuery) ([]model.UserLink, error)
+
+	// UpdateUserLink Изменяет метаданные ссылки пользователя и возвращает ссылку с новыми метаданными.
+	// Если у пользователя нет такой ссылки, возвращает ErrLinkNotFound. Оригинальная ссылка и общие настройки
+	// из patch изменяются как в RetargetLink и UpdateLinkSettings. Изменение применяется целиком или не применяется.
+	UpdateUserLink(userID model.UserID, linkID model.LinkID, patch model.LinkPatch) (model.UserLink, error)
+
+	// RetargetLink Изменяет оригинальную ссылку и сохраняет новую версию в истории.
+	// Изменить ссылку может только ее единственный владелец, иначе возвращается ErrLinkShared.
+	// После изменения ссылка больше не участвует в дедупликации.
+	RetargetLink(userID model.UserID, linkID model.LinkID, originalURL string) (model.UserLink, error)
+
+	// UpdateLinkSettings Изменяет общие для всех пользователей настройки ссылки (см. model.LinkPatch.HasLinkSettings).
+	// Как и RetargetLink, доступен только единственному владельцу ссылки.
+	UpdateLinkSettings(userID model.UserID, linkID model.LinkID, patch model.LinkPatch) (model.UserLink, error)
+
+	// GetLinkHistory Возвращает версии ссылки пользователя в порядке их создания.
+	GetLinkHistory(userID model.UserID, linkID model.LinkID) ([]model.LinkVersion, error)
+
+	// DeleteURLs Перемещает ссылки пользователя в корзину.
 	DeleteURLs(userID model.UserID, links []model.LinkID) error
 
+	// RestoreURLs Восстанавливает ссылки пользователя из корзины.
+	// Ссылки, которых нет в корзине пользователя, игнорируются.
+	RestoreURLs(userID model.UserID, links []model.LinkID) error
+
+	// SetLinkDisabled Отключает ссылку для всех пользователей или включает ее обратно.
+	// В отличие от удаления, отключение не зависит от пользователей и не убирает ссылку из их списков.
+	SetLinkDisabled(linkID model.LinkID, disabled bool) error
+
+	// AddReport Сохраняет жалобу на ссылку. Если ссылки нет, возвращает ErrLinkNotFound.
+	AddReport(linkID model.LinkID, reason string) (model.LinkReport, error)
+
+	// GetReports Возвращает жалобы на ссылки в порядке поступления.
+	// Жалобы на окончательно удаленные ссылки не возвращаются.
+	GetReports() ([]model.LinkReport, error)
+
+	// PurgeDeletedURLs Окончательно удаляет ссылки, перемещенные в корзину до deletedBefore.
+	// Ссылки, не оставшиеся ни у одного пользователя, удаляются полностью.
+	// Возвращает кол-во удаленных ссылок пользователей.
+	PurgeDeletedURLs(deletedBefore time.Time) (int, error)
+
 	Ping() error
 
 	Close() error
@@ -3,16 +3,29 @@ package repo
 import (
 	"fmt"
 	"github.com/ikashurnikov/shortener/internal/app/model"
+	"net/http"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
 
-func testStorage(newRepo func() Repo, t *testing.T) {
+func testStorage(newRepo func(opts ...Option) Repo, t *testing.T) {
 	testSaveOriginalURL(newRepo(), t)
 	testGetOriginalURLByID(newRepo(), t)
 	testGetOriginalURLsByUserID(newRepo(), t)
+	testGetLinkAuthor(newRepo(), t)
+	testForEachLink(newRepo(), t)
+	testModeration(newRepo(), t)
+	testLinkDetails(newRepo(), t)
+	testGetUserLinks(newRepo(), t)
+	testUpdateUserLink(newRepo(), t)
+	testRetargetLink(newRepo(), t)
+	testTrash(newRepo(), t)
+	testDedupPerUser(newRepo(WithDedupMode(model.DedupPerUser)), t)
+	testDedupNone(newRepo(WithDedupMode(model.DedupNone)), t)
+	testLinkIDFilter(newRepo(WithLinkIDFilter(func(id model.LinkID) bool { return id%3 == 2 })), t)
 }
 
 func testSaveOriginalURL(repo Repo, t *testing.T) {
@@ -40,6 +53,9 @@ func testGetOriginalURLByID(repo Repo, t *testing.T) {
 	_, err = repo.GetOriginalURLByID(0)
 	require.Error(t, err)
 
+	_, err = repo.GetOriginalURLByID(model.MaxLinkID)
+	require.ErrorIs(t, err, model.ErrLinkNotFound)
+
 	for i := 0; i < 10; i++ {
 		origURL := fmt.Sprintf("https://yandex.ru/%d", i)
 		id, err := repo.SaveOriginalURL(userID, origURL)
@@ -75,6 +91,501 @@ func testGetOriginalURLsByUserID(repo Repo, t *testing.T) {
 	require.True(t, user2.equal(links))
 }
 
+func testGetLinkAuthor(repo Repo, t *testing.T) {
+	user1 := newTestUser(repo, t)
+	user2 := newTestUser(repo, t)
+
+	id, err := repo.SaveOriginalURL(user1.id, "https://yandex.ru")
+	require.NoError(t, err)
+	_, err = repo.SaveOriginalURL(user2.id, "https://yandex.ru")
+	require.ErrorIs(t, err, model.ErrLinkAlreadyExists)
+
+	author, err := repo.GetLinkAuthor(id)
+	require.NoError(t, err)
+	require.Equal(t, user1.id, author)
+
+	_, err = repo.GetLinkAuthor(model.MaxLinkID)
+	require.ErrorIs(t, err, model.ErrLinkNotFound)
+}
+
+func testForEachLink(repo Repo, t *testing.T) {
+	user := newTestUser(repo, t)
+
+	kept, err := repo.SaveOriginalURL(user.id, "https://yandex.ru")
+	require.NoError(t, err)
+	purged, err := repo.SaveOriginalURL(user.id, "https://google.com")
+	require.NoError(t, err)
+	last, err := repo.SaveOriginalURL(user.id, "https://ya.ru")
+	require.NoError(t, err)
+
+	require.NoError(t, repo.DeleteURLs(user.id, []model.LinkID{purged}))
+	_, err = repo.PurgeDeletedURLs(time.Now().Add(time.Second))
+	require.NoError(t, err)
+
+	routing := model.RoutingRules{{Platforms: []model.Platform{model.PlatformIOS}, OriginalURL: "https://apps.apple.com/"}}
+	variants := model.Variants{{Name: "b", OriginalURL: "https://ya.ru/b", Weight: 1}}
+	_, err = repo.UpdateLinkSettings(user.id, last, model.LinkPatch{Routing: &routing, Variants: &variants})
+	require.NoError(t, err)
+
+	links := make(map[model.LinkID][]string)
+	err = repo.ForEachLink(func(id model.LinkID, originalURLs []string) error {
+		links[id] = originalURLs
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[model.LinkID][]string{
+		kept: {"https://yandex.ru"},
+		last: {"https://ya.ru", "https://apps.apple.com/", "https://ya.ru/b"},
+	}, links)
+
+	// Ошибка прерывает обход.
+	calls := 0
+	err = repo.ForEachLink(func(model.LinkID, []string) error {
+		calls++
+		return model.ErrInternalError
+	})
+	require.ErrorIs(t, err, model.ErrInternalError)
+	require.Equal(t, 1, calls)
+}
+
+func testModeration(repo Repo, t *testing.T) {
+	user := newTestUser(repo, t)
+
+	id, err := repo.SaveOriginalURL(user.id, "https://phishing.example")
+	require.NoError(t, err)
+
+	report, err := repo.AddReport(id, "phishing")
+	require.NoError(t, err)
+	require.Equal(t, id, report.LinkID)
+	require.Equal(t, "phishing", report.Reason)
+	require.Equal(t, "https://phishing.example", report.OriginalURL)
+	require.False(t, report.Disabled)
+
+	_, err = repo.AddReport(model.MaxLinkID, "spam")
+	require.ErrorIs(t, err, model.ErrLinkNotFound)
+
+	// Отключенная ссылка остается у пользователя, но не открывается.
+	require.NoError(t, repo.SetLinkDisabled(id, true))
+	origURL, err := repo.GetOriginalURLByID(id)
+	require.ErrorIs(t, err, model.ErrLinkDisabled)
+	require.Equal(t, "https://phishing.example", origURL)
+
+	links, err := repo.GetUserLinks(user.id, model.UserLinksQuery{})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+
+	reports, err := repo.GetReports()
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	require.Equal(t, report.ID, reports[0].ID)
+	require.True(t, reports[0].Disabled)
+
+	require.NoError(t, repo.SetLinkDisabled(id, false))
+	_, err = repo.GetOriginalURLByID(id)
+	require.NoError(t, err)
+
+	require.ErrorIs(t, repo.SetLinkDisabled(model.MaxLinkID, true), model.ErrLinkNotFound)
+}
+
+func testLinkDetails(repo Repo, t *testing.T) {
+	author := newTestUser(repo, t)
+	other := newTestUser(repo, t)
+
+	shared, err := repo.SaveOriginalURL(author.id, "https://yandex.ru")
+	require.NoError(t, err)
+	_, err = repo.SaveOriginalURL(other.id, "https://yandex.ru")
+	require.ErrorIs(t, err, model.ErrLinkAlreadyExists)
+
+	title, note := "Yandex", "Search engine"
+	_, err = repo.UpdateUserLink(author.id, shared, model.LinkPatch{Title: &title, Note: &note})
+	require.NoError(t, err)
+	otherTitle := "Other"
+	_, err = repo.UpdateUserLink(other.id, shared, model.LinkPatch{Title: &otherTitle})
+	require.NoError(t, err)
+
+	require.NoError(t, repo.CountClick(shared, model.Click{Country: "DE"}))
+	require.NoError(t, repo.CountClick(shared, model.Click{Variant: "unknown"}))
+	require.ErrorIs(t, repo.CountClick(model.MaxLinkID, model.Click{}), model.ErrLinkNotFound)
+	countryClicks := map[string]int64{"DE": 1}
+
+	// Заголовок и описание берутся у автора ссылки.
+	details, err := repo.GetLinkDetails(shared)
+	require.NoError(t, err)
+	require.Equal(t, model.LinkDetails{Title: title, Note: note, Clicks: 2, CountryClicks: countryClicks}, details)
+
+	links, err := repo.GetUserLinks(other.id, model.UserLinksQuery{})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	require.Equal(t, int64(2), links[0].Clicks)
+	require.Equal(t, countryClicks, links[0].CountryClicks)
+
+	interstitial := true
+	_, err = repo.UpdateLinkSettings(author.id, shared, model.LinkPatch{Interstitial: &interstitial})
+	require.ErrorIs(t, err, model.ErrLinkShared)
+
+	// Если автор удалил ссылку, его метаданные не показываются.
+	require.NoError(t, repo.DeleteURLs(author.id, []model.LinkID{shared}))
+	details, err = repo.GetLinkDetails(shared)
+	require.NoError(t, err)
+	require.Equal(t, model.LinkDetails{Clicks: 2, CountryClicks: countryClicks}, details)
+
+	own, err := repo.SaveOriginalURL(author.id, "https://google.com")
+	require.NoError(t, err)
+	link, err := repo.UpdateLinkSettings(author.id, own, model.LinkPatch{Interstitial: &interstitial})
+	require.NoError(t, err)
+	require.True(t, link.Interstitial)
+	require.Equal(t, 0, link.RedirectStatus)
+
+	// Поля, равные nil, не изменяются.
+	status := http.StatusMovedPermanently
+	link, err = repo.UpdateLinkSettings(author.id, own, model.LinkPatch{RedirectStatus: &status})
+	require.NoError(t, err)
+	require.True(t, link.Interstitial)
+	require.Equal(t, status, link.RedirectStatus)
+
+	details, err = repo.GetLinkDetails(own)
+	require.NoError(t, err)
+	require.Equal(t, model.LinkDetails{Interstitial: true, RedirectStatus: status}, details)
+
+	routing := model.RoutingRules{
+		{Platforms: []model.Platform{model.PlatformIOS}, OriginalURL: "https://apps.apple.com/app/id1"},
+		{Languages: []string{"ru"}, QueryParam: "app", OriginalURL: "https://google.ru"},
+	}
+	link, err = repo.UpdateLinkSettings(author.id, own, model.LinkPatch{Routing: &routing})
+	require.NoError(t, err)
+	require.Equal(t, routing, link.Routing)
+	require.Equal(t, status, link.RedirectStatus)
+
+	details, err = repo.GetLinkDetails(own)
+	require.NoError(t, err)
+	require.Equal(t, routing, details.Routing)
+
+	// Пустой список удаляет правила.
+	link, err = repo.UpdateLinkSettings(author.id, own, model.LinkPatch{Routing: &model.RoutingRules{}})
+	require.NoError(t, err)
+	require.Empty(t, link.Routing)
+	details, err = repo.GetLinkDetails(own)
+	require.NoError(t, err)
+	require.Equal(t, model.LinkDetails{Interstitial: true, RedirectStatus: status}, details)
+
+	variants := model.Variants{
+		{Name: "a", OriginalURL: "https://google.com/a", Weight: 70},
+		{Name: "b", OriginalURL: "https://google.com/b", Weight: 30},
+	}
+	_, err = repo.UpdateLinkSettings(author.id, own, model.LinkPatch{Variants: &variants})
+	require.NoError(t, err)
+	require.NoError(t, repo.CountClick(own, model.Click{Variant: "a"}))
+	require.NoError(t, repo.CountClick(own, model.Click{Variant: "b", Country: "US"}))
+	require.NoError(t, repo.CountClick(own, model.Click{Variant: "b", Country: "US"}))
+
+	// Переходы сохраняются у вариантов с прежними именами.
+	variants = model.Variants{
+		{Name: "b", OriginalURL: "https://google.com/b2", Weight: 50},
+		{Name: "c", OriginalURL: "https://google.com/c", Weight: 50},
+	}
+	link, err = repo.UpdateLinkSettings(author.id, own, model.LinkPatch{Variants: &variants})
+	require.NoError(t, err)
+	require.Equal(t, model.Variants{
+		{Name: "b", OriginalURL: "https://google.com/b2", Weight: 50, Clicks: 2},
+		{Name: "c", OriginalURL: "https://google.com/c", Weight: 50},
+	}, link.Variants)
+
+	details, err = repo.GetLinkDetails(own)
+	require.NoError(t, err)
+	require.Equal(t, link.Variants, details.Variants)
+	require.Equal(t, int64(3), details.Clicks)
+
+	link, err = repo.UpdateLinkSettings(author.id, own, model.LinkPatch{Variants: &model.Variants{}})
+	require.NoError(t, err)
+	require.Empty(t, link.Variants)
+
+	_, err = repo.UpdateLinkSettings(other.id, own, model.LinkPatch{Interstitial: &interstitial})
+	require.ErrorIs(t, err, model.ErrLinkNotFound)
+	_, err = repo.GetLinkDetails(model.MaxLinkID)
+	require.ErrorIs(t, err, model.ErrLinkNotFound)
+}
+
+func testGetUserLinks(repo Repo, t *testing.T) {
+	user := newTestUser(repo, t)
+	other := newTestUser(repo, t)
+
+	other.saveOriginalURL("https://other.com")
+	origURLs := []string{
+		"https://yandex.ru/1",
+		"https://mail.yandex.ru/2",
+		"https://google.com/yandex",
+		"https://yandex.ru/3",
+		"https://notyandex.ru/4",
+		"https://mail.yandex.ru./5",
+	}
+	for _, origURL := range origURLs {
+		user.saveOriginalURL(origURL)
+	}
+
+	collect := func(query model.UserLinksQuery) []string {
+		var res []string
+		for {
+			links, err := repo.GetUserLinks(user.id, query)
+			require.NoError(t, err)
+			for _, link := range links {
+				require.Equal(t, user.links[link.OriginalURL], link.ID)
+				res = append(res, link.OriginalURL)
+			}
+			if len(links) < query.Limit || query.Limit == 0 {
+				return res
+			}
+			query.After = &links[len(links)-1].ID
+		}
+	}
+
+	require.Equal(t, origURLs, collect(model.UserLinksQuery{}))
+	require.Equal(t, origURLs, collect(model.UserLinksQuery{Limit: 2}))
+
+	reversed := make([]string, len(origURLs))
+	for i, origURL := range origURLs {
+		reversed[len(origURLs)-1-i] = origURL
+	}
+	require.Equal(t, reversed, collect(model.UserLinksQuery{Limit: 2, Order: model.SortDesc}))
+
+	yandexURLs := []string{"https://yandex.ru/1", "https://mail.yandex.ru/2", "https://yandex.ru/3", "https://mail.yandex.ru./5"}
+	require.Equal(t, yandexURLs, collect(model.UserLinksQuery{Limit: 1, Domain: "YANDEX.ru"}))
+	require.Equal(t, yandexURLs, collect(model.UserLinksQuery{Domain: "yandex.ru."}))
+
+	require.Equal(t,
+		[]string{"https://yandex.ru/1", "https://google.com/yandex", "https://yandex.ru/3"},
+		collect(model.UserLinksQuery{Limit: 1, Contains: "/YANDEX"}))
+
+	require.Equal(t,
+		[]string{"https://google.com/yandex"},
+		collect(model.UserLinksQuery{Domain: "google.com", Contains: "yandex"}))
+
+	require.Empty(t, collect(model.UserLinksQuery{Domain: "other.com"}))
+}
+
+func testUpdateUserLink(repo Repo, t *testing.T) {
+	user := newTestUser(repo, t)
+	other := newTestUser(repo, t)
+
+	before := time.Now().Add(-time.Minute)
+	user.saveOriginalURL("https://yandex.ru")
+	user.saveOriginalURL("https://google.com")
+	linkID := user.links["https://yandex.ru"]
+
+	_, err := repo.UpdateUserLink(other.id, linkID, model.LinkPatch{})
+	require.ErrorIs(t, err, model.ErrLinkNotFound)
+
+	title := "Yandex"
+	tags := []string{"search", "ru"}
+	link, err := repo.UpdateUserLink(user.id, linkID, model.LinkPatch{Title: &title, Tags: &tags})
+	require.NoError(t, err)
+	require.Equal(t, linkID, link.ID)
+	require.Equal(t, user.id, link.UserID)
+	require.Equal(t, "https://yandex.ru", link.OriginalURL)
+	require.Equal(t, title, link.Title)
+	require.Equal(t, tags, link.Tags)
+	require.True(t, link.CreatedAt.After(before))
+
+	note := "note"
+	link, err = repo.UpdateUserLink(user.id, linkID, model.LinkPatch{Note: &note})
+	require.NoError(t, err)
+	require.Equal(t, title, link.Title)
+	require.Equal(t, note, link.Note)
+	require.Equal(t, tags, link.Tags)
+
+	links, err := repo.GetUserLinks(user.id, model.UserLinksQuery{Tag: "ru"})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	require.Equal(t, link.Title, links[0].Title)
+	require.Equal(t, link.Note, links[0].Note)
+
+	links, err = repo.GetUserLinks(user.id, model.UserLinksQuery{Contains: "yandex"})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	require.Equal(t, linkID, links[0].ID)
+
+	// Оригинальная ссылка, общие настройки и метаданные изменяются вместе.
+	originalURL, interstitial, status := "https://yandex.ru/search", true, http.StatusFound
+	title = "Yandex Search"
+	link, err = repo.UpdateUserLink(user.id, linkID, model.LinkPatch{
+		Title: &title, OriginalURL: &originalURL, Interstitial: &interstitial, RedirectStatus: &status,
+	})
+	require.NoError(t, err)
+	require.Equal(t, title, link.Title)
+	require.Equal(t, originalURL, link.OriginalURL)
+	require.True(t, link.Interstitial)
+	require.Equal(t, status, link.RedirectStatus)
+
+	// Изменение общей ссылки не применяется и частично.
+	sharedID, err := repo.SaveOriginalURL(user.id, "https://shared.example")
+	require.NoError(t, err)
+	_, err = repo.SaveOriginalURL(other.id, "https://shared.example")
+	require.ErrorIs(t, err, model.ErrLinkAlreadyExists)
+	sharedTitle, sharedURL := "Shared", "https://shared.example/new"
+	_, err = repo.UpdateUserLink(user.id, sharedID, model.LinkPatch{Title: &sharedTitle, OriginalURL: &sharedURL})
+	require.ErrorIs(t, err, model.ErrLinkShared)
+	links, err = repo.GetUserLinks(user.id, model.UserLinksQuery{Contains: "shared"})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	require.Empty(t, links[0].Title)
+	require.Equal(t, "https://shared.example", links[0].OriginalURL)
+
+	require.NoError(t, repo.DeleteURLs(user.id, []model.LinkID{linkID}))
+	_, err = repo.UpdateUserLink(user.id, linkID, model.LinkPatch{Title: &title})
+	require.ErrorIs(t, err, model.ErrLinkNotFound)
+}
+
+func testRetargetLink(repo Repo, t *testing.T) {
+	user := newTestUser(repo, t)
+	other := newTestUser(repo, t)
+
+	user.saveOriginalURL("https://yandex.ru/typo")
+	linkID := user.links["https://yandex.ru/typo"]
+
+	_, err := repo.RetargetLink(other.id, linkID, "https://yandex.ru/fixed")
+	require.ErrorIs(t, err, model.ErrLinkNotFound)
+
+	link, err := repo.RetargetLink(user.id, linkID, "https://yandex.ru/fixed")
+	require.NoError(t, err)
+	require.Equal(t, linkID, link.ID)
+	require.Equal(t, "https://yandex.ru/fixed", link.OriginalURL)
+
+	origURL, err := repo.GetOriginalURLByID(linkID)
+	require.NoError(t, err)
+	require.Equal(t, "https://yandex.ru/fixed", origURL)
+
+	// Измененная ссылка не участвует в дедупликации.
+	id, err := repo.SaveOriginalURL(other.id, "https://yandex.ru/typo")
+	require.NoError(t, err)
+	require.NotEqual(t, linkID, id)
+
+	history, err := repo.GetLinkHistory(user.id, linkID)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	require.Equal(t, 1, history[0].Version)
+	require.Equal(t, "https://yandex.ru/typo", history[0].OriginalURL)
+	require.Equal(t, user.id, history[0].Author)
+	require.Equal(t, 2, history[1].Version)
+	require.Equal(t, "https://yandex.ru/fixed", history[1].OriginalURL)
+	require.Equal(t, user.id, history[1].Author)
+
+	_, err = repo.GetLinkHistory(other.id, linkID)
+	require.ErrorIs(t, err, model.ErrLinkNotFound)
+
+	// Ссылку, сохраненную несколькими пользователями, изменить нельзя.
+	user.saveOriginalURL("https://google.com")
+	other.saveOriginalURL("https://google.com")
+	_, err = repo.RetargetLink(user.id, user.links["https://google.com"], "https://google.ru")
+	require.ErrorIs(t, err, model.ErrLinkShared)
+}
+
+func testTrash(repo Repo, t *testing.T) {
+	user := newTestUser(repo, t)
+	other := newTestUser(repo, t)
+
+	user.saveOriginalURL("https://yandex.ru")
+	user.saveOriginalURL("https://google.com")
+	other.saveOriginalURL("https://google.com")
+	yandexID := user.links["https://yandex.ru"]
+	googleID := user.links["https://google.com"]
+
+	require.NoError(t, repo.DeleteURLs(user.id, []model.LinkID{yandexID, googleID}))
+
+	links, err := repo.GetUserLinks(user.id, model.UserLinksQuery{})
+	require.NoError(t, err)
+	require.Empty(t, links)
+
+	trash, err := repo.GetUserLinks(user.id, model.UserLinksQuery{Trash: true})
+	require.NoError(t, err)
+	require.Len(t, trash, 2)
+	for _, link := range trash {
+		require.NotNil(t, link.DeletedAt)
+	}
+
+	require.NoError(t, repo.RestoreURLs(user.id, []model.LinkID{yandexID}))
+	links, err = repo.GetUserLinks(user.id, model.UserLinksQuery{})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	require.Equal(t, yandexID, links[0].ID)
+	require.Nil(t, links[0].DeletedAt)
+
+	// Ссылки, удаленные позже deletedBefore, не удаляются.
+	count, err := repo.PurgeDeletedURLs(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	count, err = repo.PurgeDeletedURLs(time.Now().Add(time.Second))
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	trash, err = repo.GetUserLinks(user.id, model.UserLinksQuery{Trash: true})
+	require.NoError(t, err)
+	require.Empty(t, trash)
+
+	// Ссылка другого пользователя продолжает работать.
+	origURL, err := repo.GetOriginalURLByID(googleID)
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", origURL)
+
+	// Ссылка, удаленная из корзин всех пользователей, удаляется полностью.
+	require.NoError(t, repo.DeleteURLs(other.id, []model.LinkID{googleID}))
+	count, err = repo.PurgeDeletedURLs(time.Now().Add(time.Second))
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	_, err = repo.GetOriginalURLByID(googleID)
+	require.ErrorIs(t, err, model.ErrLinkNotFound)
+
+	id, err := repo.SaveOriginalURL(other.id, "https://google.com")
+	require.NoError(t, err)
+	require.NotEqual(t, googleID, id)
+}
+
+func testDedupPerUser(repo Repo, t *testing.T) {
+	user := newTestUser(repo, t)
+	other := newTestUser(repo, t)
+
+	id, err := repo.SaveOriginalURL(user.id, "https://yandex.ru")
+	require.NoError(t, err)
+
+	id2, err := repo.SaveOriginalURL(user.id, "https://yandex.ru")
+	require.ErrorIs(t, err, model.ErrLinkAlreadyExists)
+	require.Equal(t, id, id2)
+
+	otherID, err := repo.SaveOriginalURL(other.id, "https://yandex.ru")
+	require.NoError(t, err)
+	require.NotEqual(t, id, otherID)
+
+	ids, err := repo.SaveOriginalURLs(other.id, []string{"https://yandex.ru", "https://google.com"})
+	require.NoError(t, err)
+	require.Equal(t, otherID, ids[0])
+
+	// У каждой ссылки один владелец, поэтому ее можно изменить.
+	_, err = repo.RetargetLink(user.id, id, "https://yandex.ru/fixed")
+	require.NoError(t, err)
+}
+
+func testDedupNone(repo Repo, t *testing.T) {
+	user := newTestUser(repo, t)
+
+	id, err := repo.SaveOriginalURL(user.id, "https://yandex.ru")
+	require.NoError(t, err)
+
+	id2, err := repo.SaveOriginalURL(user.id, "https://yandex.ru")
+	require.NoError(t, err)
+	require.NotEqual(t, id, id2)
+
+	ids, err := repo.SaveOriginalURLs(user.id, []string{"https://yandex.ru", "https://yandex.ru"})
+	require.NoError(t, err)
+	require.Len(t, ids, 2)
+	require.NotEqual(t, ids[0], ids[1])
+	require.NotContains(t, ids, id)
+	require.NotContains(t, ids, id2)
+
+	links, err := repo.GetUserLinks(user.id, model.UserLinksQuery{})
+	require.NoError(t, err)
+	require.Len(t, links, 4)
+}
+
 type testUser struct {
 	id    model.UserID
 	links map[string]model.LinkID
@@ -82,6 +593,29 @@ type testUser struct {
 	t     *testing.T
 }
 
+func testLinkIDFilter(repo Repo, t *testing.T) {
+	userID, err := repo.AddUser()
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		origURL := fmt.Sprintf("https://yandex.ru/%d", i)
+		id, err := repo.SaveOriginalURL(userID, origURL)
+		require.NoError(t, err)
+		require.Equal(t, model.LinkID(2), id%3)
+
+		_, err = repo.GetOriginalURLByID(id - 1)
+		require.ErrorIs(t, err, model.ErrLinkNotFound)
+	}
+
+	ids, err := repo.SaveOriginalURLs(userID, []string{"https://google.com", "https://yandex.ru/0"})
+	require.NoError(t, err)
+	require.Equal(t, model.LinkID(2), ids[0]%3)
+
+	links, err := repo.GetUserLinks(userID, model.UserLinksQuery{})
+	require.NoError(t, err)
+	require.Len(t, links, 6)
+}
+
 func newTestUser(repo Repo, t *testing.T) testUser {
 	id, err := repo.AddUser()
 	require.NoError(t, err)
@@ -0,0 +1,64 @@
+package repo
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// TrashPurger Фоновая задача, окончательно удаляющая ссылки, которые находятся в корзине дольше срока хранения.
+type TrashPurger struct {
+	repo      Repo
+	retention time.Duration
+	interval  time.Duration
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func NewTrashPurger(repo Repo, retention time.Duration, interval time.Duration) *TrashPurger {
+	return &TrashPurger{
+		repo:      repo,
+		retention: retention,
+		interval:  interval,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start Запускает очистку корзины с периодом interval.
+func (p *TrashPurger) Start() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			p.Purge()
+
+			select {
+			case <-ticker.C:
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop Останавливает очистку корзины и дожидается завершения текущей очистки.
+func (p *TrashPurger) Stop() {
+	close(p.stop)
+	p.wg.Wait()
+}
+
+// Purge Удаляет из корзины ссылки с истекшим сроком хранения.
+func (p *TrashPurger) Purge() {
+	count, err := p.repo.PurgeDeletedURLs(time.Now().Add(-p.retention))
+	if err != nil {
+		log.Printf("trash purge failed: %v", err)
+		return
+	}
+	if count != 0 {
+		log.Printf("trash purge: %d links removed", count)
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"strings"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+)
+
+// Base62Alphabet Алфавит по умолчанию для кодирования ID ссылок в системе счисления по основанию 62.
+const Base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// confusables Группы похожих символов. Символ вне алфавита заменяется первым символом своей группы из алфавита.
+var confusables = []string{"0oO", "1lLI|", "2zZ", "5sS", "8B"}
+
+// alphabet Позиционная система счисления с произвольным алфавитом.
+type alphabet struct {
+	symbols string
+	index   [256]int
+	// canonical Замена символа вне алфавита символом алфавита (0, если замены нет).
+	canonical [256]byte
+}
+
+func newAlphabet(symbols string) (*alphabet, error) {
+	if len(symbols) < 2 {
+		return nil, model.ErrInvalidAlphabet
+	}
+
+	a := &alphabet{symbols: symbols}
+	for i := range a.index {
+		a.index[i] = -1
+	}
+	for i := 0; i < len(symbols); i++ {
+		c := symbols[i]
+		if c >= 0x80 || a.index[c] != -1 {
+			return nil, model.ErrInvalidAlphabet
+		}
+		a.index[c] = i
+	}
+	a.initCanonical()
+	return a, nil
+}
+
+// initCanonical Заполняет замены символов вне алфавита: сначала тем же символом в другом регистре,
+// затем похожим символом.
+func (a *alphabet) initCanonical() {
+	for c := 0; c < 0x80; c++ {
+		if a.index[c] != -1 {
+			continue
+		}
+
+		if other := swapCase(byte(c)); a.index[other] != -1 {
+			a.canonical[c] = other
+			continue
+		}
+
+		for _, group := range confusables {
+			if strings.IndexByte(group, byte(c)) == -1 {
+				continue
+			}
+			for i := 0; i < len(group); i++ {
+				if a.index[group[i]] != -1 {
+					a.canonical[c] = group[i]
+					break
+				}
+			}
+		}
+	}
+}
+
+// normalize Заменяет символы вне алфавита их каноническими символами.
+// Символы, для которых замены нет, остаются без изменений.
+func (a *alphabet) normalize(str string) string {
+	var buf []byte
+	for i := 0; i < len(str); i++ {
+		if c := a.canonical[str[i]]; c != 0 {
+			if buf == nil {
+				buf = []byte(str)
+			}
+			buf[i] = c
+		}
+	}
+
+	if buf == nil {
+		return str
+	}
+	return string(buf)
+}
+
+func swapCase(c byte) byte {
+	switch {
+	case 'a' <= c && c <= 'z':
+		return c - 'a' + 'A'
+	case 'A' <= c && c <= 'Z':
+		return c - 'A' + 'a'
+	}
+	return c
+}
+
+func (a *alphabet) base() uint64 {
+	return uint64(len(a.symbols))
+}
+
+// width Возвращает минимальное кол-во символов для записи числа.
+// Для максимального значения - кол-во символов, достаточное для записи любого числа из диапазона.
+func (a *alphabet) width(value uint64) int {
+	res := 1
+	for value /= a.base(); value != 0; value /= a.base() {
+		res++
+	}
+	return res
+}
+
+// encode Записывает число ровно width символами, дополняя его слева нулевым символом.
+func (a *alphabet) encode(value uint64, width int) string {
+	buf := make([]byte, width)
+	for i := width - 1; i >= 0; i-- {
+		buf[i] = a.symbols[value%a.base()]
+		value /= a.base()
+	}
+	return string(buf)
+}
+
+// decode Декодирует число. Возвращает false, если строка содержит символы вне алфавита или число больше max.
+func (a *alphabet) decode(str string, max uint64) (uint64, bool) {
+	var res uint64
+	for i := 0; i < len(str); i++ {
+		digit := a.index[str[i]]
+		if digit == -1 || uint64(digit) > max {
+			return 0, false
+		}
+		if res > (max-uint64(digit))/a.base() {
+			return 0, false
+		}
+		res = res*a.base() + uint64(digit)
+	}
+	return res, true
+}
+
+// shuffled Возвращает алфавит с символами, переставленными в зависимости от ключа.
+func (a *alphabet) shuffled(key []byte) *alphabet {
+	symbols := []byte(a.symbols)
+	rnd := newKeyStream(key, "alphabet")
+	for i := len(symbols) - 1; i > 0; i-- {
+		j := rnd.next() % uint64(i+1)
+		symbols[i], symbols[j] = symbols[j], symbols[i]
+	}
+
+	res, _ := newAlphabet(string(symbols))
+	return res
+}
+
+// keyStream Детерминированная псевдослучайная последовательность, зависящая от ключа.
+type keyStream struct {
+	state uint64
+}
+
+func newKeyStream(key []byte, purpose string) *keyStream {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return &keyStream{state: binary.LittleEndian.Uint64(mac.Sum(nil))}
+}
+
+// next Возвращает следующее число последовательности (splitmix64).
+func (s *keyStream) next() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	return mix64(s.state)
+}
+
+func mix64(z uint64) uint64 {
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"github.com/ikashurnikov/shortener/internal/app/model"
+)
+
+// BaseNLinkIDEncoder Кодирует ID ссылки строкой минимальной длины в заданном алфавите (по умолчанию base62).
+//
+// У каждого ID ровно один код: декодер отвергает коды с лишними ведущими нулевыми символами.
+// Длины, зарезервированные ReserveLength, не выдаются, что позволяет не пересекаться с кодами
+// прежнего формата (например, 7-символьными кодами ZBase32LinkIDEncoder).
+type BaseNLinkIDEncoder struct {
+	alphabet *alphabet
+	reserved map[int]bool
+}
+
+func NewBaseNLinkIDEncoder(symbols string) (*BaseNLinkIDEncoder, error) {
+	a, err := newAlphabet(symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BaseNLinkIDEncoder{
+		alphabet: a,
+		reserved: make(map[int]bool),
+	}, nil
+}
+
+func NewBase62LinkIDEncoder() *BaseNLinkIDEncoder {
+	e, _ := NewBaseNLinkIDEncoder(Base62Alphabet)
+	return e
+}
+
+// ReserveLength Запрещает коды длины n. ID, для записи которых нужно n символов,
+// записываются ближайшей большей незарезервированной длиной.
+func (e *BaseNLinkIDEncoder) ReserveLength(n int) {
+	e.reserved[n] = true
+}
+
+func (e *BaseNLinkIDEncoder) EncodeToString(linkID model.LinkID) (string, error) {
+	value := uint64(linkID)
+	return e.alphabet.encode(value, e.codeLen(value)), nil
+}
+
+func (e *BaseNLinkIDEncoder) DecodeFromString(str string) (model.LinkID, error) {
+	if str == "" {
+		return 0, model.ErrDecodingShortURL
+	}
+
+	str = e.NormalizeCode(str)
+
+	value, ok := e.alphabet.decode(str, uint64(model.MaxLinkID))
+	if !ok || len(str) != e.codeLen(value) {
+		return 0, model.ErrDecodingShortURL
+	}
+	return model.LinkID(value), nil
+}
+
+func (e *BaseNLinkIDEncoder) NormalizeCode(str string) string {
+	return e.alphabet.normalize(str)
+}
+
+// codeLen Возвращает длину канонического кода.
+func (e *BaseNLinkIDEncoder) codeLen(value uint64) int {
+	n := e.alphabet.width(value)
+	for e.reserved[n] {
+		n++
+	}
+	return n
+}
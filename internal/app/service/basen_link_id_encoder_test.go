@@ -0,0 +1,144 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBase62LinkIDEncoder_EncodeToString(t *testing.T) {
+	tests := []struct {
+		value model.LinkID
+		want  string
+	}{
+		{value: 0, want: "0"},
+		{value: 1, want: "1"},
+		{value: 61, want: "z"},
+		{value: 62, want: "10"},
+		{value: 3843, want: "zz"},
+		{value: math.MaxUint32, want: "4gfFC3"},
+		{value: 56800235584, want: "1000000"},
+		{value: model.MaxLinkID, want: "AzL8n0Y58m7"},
+	}
+	for _, tt := range tests {
+		name := fmt.Sprintf("encoding %v", tt.value)
+		t.Run(name, func(t *testing.T) {
+			encoder := NewBase62LinkIDEncoder()
+			got, err := encoder.EncodeToString(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			id, err := encoder.DecodeFromString(got)
+			require.NoError(t, err)
+			assert.Equal(t, tt.value, id)
+		})
+	}
+}
+
+func TestBase62LinkIDEncoder_DecodeFromString(t *testing.T) {
+	tests := []struct {
+		name string
+		str  string
+	}{
+		{name: "empty string", str: ""},
+		{name: "leading zero", str: "01"},
+		{name: "zeros only", str: "00"},
+		{name: "invalid symbols", str: "ab-c"},
+		{name: "value out of range", str: "AzL8n0Y58m8"},
+		{name: "too long string", str: "100000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoder := NewBase62LinkIDEncoder()
+			_, err := encoder.DecodeFromString(tt.str)
+			assert.ErrorIs(t, err, model.ErrDecodingShortURL)
+		})
+	}
+}
+
+func TestBaseNLinkIDEncoder_Alphabet(t *testing.T) {
+	for _, symbols := range []string{"", "a", "abca", "abcщ"} {
+		_, err := NewBaseNLinkIDEncoder(symbols)
+		assert.ErrorIs(t, err, model.ErrInvalidAlphabet, symbols)
+	}
+
+	encoder, err := NewBaseNLinkIDEncoder("01")
+	require.NoError(t, err)
+	code, err := encoder.EncodeToString(5)
+	require.NoError(t, err)
+	assert.Equal(t, "101", code)
+}
+
+func TestBaseNLinkIDEncoder_ReserveLength(t *testing.T) {
+	encoder, err := NewBaseNLinkIDEncoder("0123456789")
+	require.NoError(t, err)
+	encoder.ReserveLength(2)
+	encoder.ReserveLength(3)
+
+	tests := []struct {
+		value model.LinkID
+		want  string
+	}{
+		{value: 9, want: "9"},
+		{value: 10, want: "0010"},
+		{value: 999, want: "0999"},
+		{value: 1000, want: "1000"},
+	}
+	for _, tt := range tests {
+		code, err := encoder.EncodeToString(tt.value)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, code)
+
+		id, err := encoder.DecodeFromString(code)
+		require.NoError(t, err)
+		assert.Equal(t, tt.value, id)
+	}
+
+	for _, str := range []string{"10", "100", "0009", "00010"} {
+		_, err := encoder.DecodeFromString(str)
+		assert.ErrorIs(t, err, model.ErrDecodingShortURL, str)
+	}
+
+	// Коды zbase32 не пересекаются с кодами base62 при зарезервированной длине.
+	base62 := NewBase62LinkIDEncoder()
+	base62.ReserveLength(ZBase32CodeLen)
+	legacy, err := NewZBase32LinkIDEncoder().EncodeToString(42)
+	require.NoError(t, err)
+	_, err = base62.DecodeFromString(legacy)
+	assert.ErrorIs(t, err, model.ErrDecodingShortURL)
+}
+
+func TestBaseNLinkIDEncoder_NormalizeCode(t *testing.T) {
+	// В base62 регистр и похожие символы различаются.
+	base62 := NewBase62LinkIDEncoder()
+	assert.Equal(t, "O0lI1a", base62.NormalizeCode("O0lI1a"))
+
+	base36, err := NewBaseNLinkIDEncoder("0123456789abcdefghijklmnopqrstuvwxyz")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", base36.NormalizeCode("ABC"))
+
+	// Алфавит без o и l.
+	base32, err := NewBaseNLinkIDEncoder("0123456789abcdefghjkmnpqrstvwxyz")
+	require.NoError(t, err)
+	assert.Equal(t, "1001", base32.NormalizeCode("lOo1"))
+
+	id, err := base32.DecodeFromString("LOO1")
+	require.NoError(t, err)
+	want, err := base32.DecodeFromString("1001")
+	require.NoError(t, err)
+	assert.Equal(t, want, id)
+}
+
+func TestFallbackLinkIDEncoder_NormalizeCode(t *testing.T) {
+	base62 := NewBase62LinkIDEncoder()
+	base62.ReserveLength(ZBase32CodeLen)
+	encoder := NewFallbackLinkIDEncoder(base62, NewZBase32LinkIDEncoder())
+
+	assert.Equal(t, "Ab0", encoder.NormalizeCode("Ab0"))
+	assert.Equal(t, "999999a", encoder.NormalizeCode("999999A"))
+	assert.Equal(t, "[][]", encoder.NormalizeCode("[][]"))
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"bufio"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+)
+
+// Blocklist Список фишинговых и вредоносных адресов, загружаемый из локальных файлов.
+//
+// Файл хостов содержит по одному хосту в строке, хост блокирует и свои поддомены.
+// Файл префиксов содержит по одной ссылке в строке и блокирует ссылки, начинающиеся с нее.
+// Пустые строки и строки, начинающиеся с #, пропускаются. Любой из файлов может быть не задан.
+//
+// Start запускает периодическую проверку файлов: измененный файл перечитывается без перезапуска сервиса.
+type Blocklist struct {
+	hostsFile    string
+	prefixesFile string
+	// canonicalizer Правила, которыми приводятся к каноническому виду оригинальные ссылки.
+	// Префиксы приводятся к тому же виду, чтобы совпадать с сохраненными ссылками.
+	canonicalizer model.URLCanonicalization
+
+	guard    sync.RWMutex
+	hosts    map[string]struct{}
+	prefixes []string
+	versions map[string]fileVersion
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// fileVersion Время изменения и размер загруженного файла.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewBlocklist Создает список и загружает файлы hostsFile и prefixesFile. Префиксы приводятся
+// к каноническому виду правилами canonicalizer, которыми сервис приводит оригинальные ссылки.
+func NewBlocklist(hostsFile string, prefixesFile string, canonicalizer model.URLCanonicalization) (*Blocklist, error) {
+	b := &Blocklist{
+		hostsFile:     hostsFile,
+		prefixesFile:  prefixesFile,
+		canonicalizer: canonicalizer,
+		hosts:         make(map[string]struct{}),
+		versions:      make(map[string]fileVersion),
+		stop:          make(chan struct{}),
+	}
+	if _, err := b.Reload(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Match Проверяет оригинальную ссылку. Возвращает правило, по которому ссылка заблокирована.
+func (b *Blocklist) Match(originalURL string) (string, bool) {
+	b.guard.RLock()
+	defer b.guard.RUnlock()
+
+	if u, err := url.Parse(originalURL); err == nil {
+		host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+		for host != "" {
+			if _, ok := b.hosts[host]; ok {
+				return host, true
+			}
+			_, host, _ = strings.Cut(host, ".")
+		}
+	}
+
+	for _, prefix := range b.prefixes {
+		if strings.HasPrefix(originalURL, prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// Reload Перечитывает файлы, измененные с момента прошлой загрузки. Возвращает true, если список изменился.
+// Если файл не удалось прочитать, остается прежний список.
+func (b *Blocklist) Reload() (bool, error) {
+	hostsChanged, hostsVersion, err := b.changed(b.hostsFile)
+	if err != nil {
+		return false, err
+	}
+	prefixesChanged, prefixesVersion, err := b.changed(b.prefixesFile)
+	if err != nil {
+		return false, err
+	}
+	if !hostsChanged && !prefixesChanged {
+		return false, nil
+	}
+
+	var hosts map[string]struct{}
+	if hostsChanged {
+		hosts = make(map[string]struct{})
+		err = readListFile(b.hostsFile, func(line string) {
+			hosts[strings.TrimSuffix(strings.ToLower(line), ".")] = struct{}{}
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	var prefixes []string
+	if prefixesChanged {
+		err = readListFile(b.prefixesFile, func(line string) {
+			// Ссылки сравниваются в каноническом виде.
+			if canonical, err := b.canonicalizer.Canonicalize(line); err == nil {
+				line = canonical
+			}
+			prefixes = append(prefixes, line)
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	b.guard.Lock()
+	defer b.guard.Unlock()
+
+	if hostsChanged {
+		b.hosts = hosts
+		b.versions[b.hostsFile] = hostsVersion
+	}
+	if prefixesChanged {
+		b.prefixes = prefixes
+		b.versions[b.prefixesFile] = prefixesVersion
+	}
+	return true, nil
+}
+
+// Start Запускает проверку изменений файлов с периодом interval.
+func (b *Blocklist) Start(interval time.Duration) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-b.stop:
+				return
+			}
+
+			if changed, err := b.Reload(); err != nil {
+				log.Printf("blocklist reload failed: %v", err)
+			} else if changed {
+				log.Printf("blocklist reloaded")
+			}
+		}
+	}()
+}
+
+// Stop Останавливает проверку изменений файлов.
+func (b *Blocklist) Stop() {
+	close(b.stop)
+	b.wg.Wait()
+}
+
+// changed Проверяет, изменился ли файл с момента прошлой загрузки.
+func (b *Blocklist) changed(filename string) (bool, fileVersion, error) {
+	if filename == "" {
+		return false, fileVersion{}, nil
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return false, fileVersion{}, err
+	}
+
+	version := fileVersion{modTime: info.ModTime(), size: info.Size()}
+
+	b.guard.RLock()
+	defer b.guard.RUnlock()
+
+	loaded, ok := b.versions[filename]
+	return !ok || loaded != version, version, nil
+}
+
+func readListFile(filename string, add func(line string)) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		add(line)
+	}
+	return scanner.Err()
+}
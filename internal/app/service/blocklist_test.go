@@ -0,0 +1,156 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeListFile(t *testing.T, filename string, content string) {
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0o600))
+}
+
+func TestBlocklist_Match(t *testing.T) {
+	dir := t.TempDir()
+	hostsFile := filepath.Join(dir, "hosts.txt")
+	prefixesFile := filepath.Join(dir, "prefixes.txt")
+	writeListFile(t, hostsFile, "# phishing\nevil.example\n\nBAD.test.\n")
+	writeListFile(t, prefixesFile, "HTTPS://Share.example/evil/\n")
+
+	blocklist, err := NewBlocklist(hostsFile, prefixesFile, model.DefaultURLCanonicalization)
+	require.NoError(t, err)
+
+	tests := []struct {
+		originalURL string
+		rule        string
+	}{
+		{originalURL: "https://evil.example/login", rule: "evil.example"},
+		{originalURL: "http://www.evil.example", rule: "evil.example"},
+		{originalURL: "http://EVIL.example./", rule: "evil.example"},
+		{originalURL: "https://bad.test:8443/", rule: "bad.test"},
+		{originalURL: "https://share.example/evil/file.exe", rule: "https://share.example/evil/"},
+		{originalURL: "https://share.example/good/file.exe"},
+		{originalURL: "https://notevil.example/"},
+		{originalURL: "https://example/"},
+	}
+	for _, tt := range tests {
+		rule, ok := blocklist.Match(tt.originalURL)
+		assert.Equal(t, tt.rule != "", ok, tt.originalURL)
+		assert.Equal(t, tt.rule, rule, tt.originalURL)
+	}
+}
+
+func TestBlocklist_Reload(t *testing.T) {
+	hostsFile := filepath.Join(t.TempDir(), "hosts.txt")
+	writeListFile(t, hostsFile, "evil.example\n")
+
+	blocklist, err := NewBlocklist(hostsFile, "", model.DefaultURLCanonicalization)
+	require.NoError(t, err)
+
+	changed, err := blocklist.Reload()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	writeListFile(t, hostsFile, "other.example\nphish.example\n")
+	changed, err = blocklist.Reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	_, ok := blocklist.Match("https://evil.example/")
+	assert.False(t, ok)
+	_, ok = blocklist.Match("https://phish.example/")
+	assert.True(t, ok)
+
+	// Если файл пропал, остается прежний список.
+	require.NoError(t, os.Remove(hostsFile))
+	_, err = blocklist.Reload()
+	assert.Error(t, err)
+	_, ok = blocklist.Match("https://phish.example/")
+	assert.True(t, ok)
+
+	_, err = NewBlocklist(hostsFile, "", model.DefaultURLCanonicalization)
+	assert.Error(t, err)
+}
+
+func TestShortener_Blocklist(t *testing.T) {
+	hostsFile := filepath.Join(t.TempDir(), "hosts.txt")
+	writeListFile(t, hostsFile, "evil.example\n")
+
+	blocklist, err := NewBlocklist(hostsFile, "", model.DefaultURLCanonicalization)
+	require.NoError(t, err)
+
+	s := newTestShortener(t, WithBlocklist(blocklist))
+
+	userID := model.UserID(model.InvalidUserID)
+	_, err = s.CreateLink(&userID, "https://www.evil.example/login")
+	assert.ErrorIs(t, err, model.ErrBlockedDestination)
+	_, err = s.CreateLinks(&userID, []string{"https://yandex.ru", "https://evil.example"})
+	assert.ErrorIs(t, err, model.ErrBlockedDestination)
+
+	link, code := createCode(t, s, &userID, "https://phish.example/login")
+
+	blocked, err := s.GetBlockedLinks()
+	require.NoError(t, err)
+	assert.Empty(t, blocked)
+
+	// Ссылка, сохраненная до попадания в список, перестает работать.
+	writeListFile(t, hostsFile, "evil.example\nphish.example\n")
+	_, err = blocklist.Reload()
+	require.NoError(t, err)
+
+	got, err := s.GetLinkByShortURL(code, model.Visit{})
+	assert.ErrorIs(t, err, model.ErrBlockedDestination)
+	assert.Equal(t, link.OriginalURL, got.OriginalURL)
+
+	blocked, err = s.GetBlockedLinks()
+	require.NoError(t, err)
+	assert.Equal(t, []model.BlockedLink{{ShortURL: link.ShortURL, OriginalURL: link.OriginalURL, Rule: "phish.example"}}, blocked)
+
+	// В отчет попадают и ссылки, заблокированные только в правилах перехода или вариантах.
+	routed, routedCode := createCode(t, s, &userID, "https://shop.example/")
+	routing := model.RoutingRules{{Platforms: []model.Platform{model.PlatformAndroid}, OriginalURL: "https://android.example/app"}}
+	_, err = s.UpdateLink(userID, routedCode, model.LinkPatch{Routing: &routing})
+	require.NoError(t, err)
+
+	tested, testedCode := createCode(t, s, &userID, "https://landing.example/")
+	variants := model.Variants{
+		{Name: "a", OriginalURL: "https://landing.example/a", Weight: 1},
+		{Name: "b", OriginalURL: "https://variant.example/b", Weight: 1},
+	}
+	_, err = s.UpdateLink(userID, testedCode, model.LinkPatch{Variants: &variants})
+	require.NoError(t, err)
+
+	writeListFile(t, hostsFile, "phish.example\nandroid.example\nvariant.example\n")
+	_, err = blocklist.Reload()
+	require.NoError(t, err)
+
+	blocked, err = s.GetBlockedLinks()
+	require.NoError(t, err)
+	assert.Equal(t, []model.BlockedLink{
+		{ShortURL: link.ShortURL, OriginalURL: link.OriginalURL, Rule: "phish.example"},
+		{ShortURL: routed.ShortURL, OriginalURL: "https://android.example/app", Rule: "android.example"},
+		{ShortURL: tested.ShortURL, OriginalURL: "https://variant.example/b", Rule: "variant.example"},
+	}, blocked)
+}
+
+func TestShortener_BlocklistCanonicalization(t *testing.T) {
+	prefixesFile := filepath.Join(t.TempDir(), "prefixes.txt")
+	writeListFile(t, prefixesFile, "https://share.example/download?id=1&file=evil\n")
+
+	canonicalizer := model.DefaultURLCanonicalization | model.CanonicalSortQuery
+	blocklist, err := NewBlocklist("", prefixesFile, canonicalizer)
+	require.NoError(t, err)
+
+	s := newTestShortener(t, WithURLCanonicalization(canonicalizer), WithBlocklist(blocklist))
+
+	// Префикс и ссылка приводятся к одному виду: параметры сортируются.
+	userID := model.UserID(model.InvalidUserID)
+	_, err = s.CreateLink(&userID, "https://share.example/download?id=1&file=evil")
+	assert.ErrorIs(t, err, model.ErrBlockedDestination)
+	_, err = s.CreateLink(&userID, "https://share.example/download?file=evil&id=1")
+	assert.ErrorIs(t, err, model.ErrBlockedDestination)
+}
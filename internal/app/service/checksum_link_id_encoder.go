@@ -0,0 +1,86 @@
+package service
+
+import "github.com/ikashurnikov/shortener/internal/app/model"
+
+// ChecksumLinkIDEncoder Добавляет к коду другого кодировщика контрольный символ (алгоритм Луна по модулю N).
+//
+// Контрольный символ выявляет любую замену одного символа и большинство перестановок соседних символов,
+// поэтому опечатка в коде не приводит на чужую ссылку, а возвращает ошибку model.ErrInvalidChecksum.
+// Алфавит должен содержать все символы кодов исходного кодировщика.
+type ChecksumLinkIDEncoder struct {
+	inner    LinkIDEncoder
+	alphabet *alphabet
+}
+
+func NewChecksumLinkIDEncoder(inner LinkIDEncoder, symbols string) (*ChecksumLinkIDEncoder, error) {
+	a, err := newAlphabet(symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChecksumLinkIDEncoder{
+		inner:    inner,
+		alphabet: a,
+	}, nil
+}
+
+func (e *ChecksumLinkIDEncoder) EncodeToString(linkID model.LinkID) (string, error) {
+	code, err := e.inner.EncodeToString(linkID)
+	if err != nil {
+		return "", err
+	}
+
+	sum, ok := e.luhnSum(code, 2)
+	if !ok {
+		return "", model.ErrInvalidAlphabet
+	}
+
+	n := int(e.alphabet.base())
+	return code + string(e.alphabet.symbols[(n-sum%n)%n]), nil
+}
+
+func (e *ChecksumLinkIDEncoder) DecodeFromString(str string) (model.LinkID, error) {
+	if len(str) < 2 {
+		return 0, model.ErrDecodingShortURL
+	}
+
+	str = e.NormalizeCode(str)
+
+	sum, ok := e.luhnSum(str, 1)
+	if !ok {
+		return 0, model.ErrDecodingShortURL
+	}
+	if sum%int(e.alphabet.base()) != 0 {
+		return 0, model.ErrInvalidChecksum
+	}
+
+	return e.inner.DecodeFromString(str[:len(str)-1])
+}
+
+// NormalizeCode Приводит к каноническому виду код исходного кодировщика и контрольный символ.
+func (e *ChecksumLinkIDEncoder) NormalizeCode(str string) string {
+	if str == "" {
+		return str
+	}
+
+	last := len(str) - 1
+	return e.inner.NormalizeCode(str[:last]) + e.alphabet.normalize(str[last:])
+}
+
+// luhnSum Вычисляет сумму алгоритма Луна, начиная с последнего символа с множителем factor.
+// Возвращает false, если строка содержит символы вне алфавита.
+func (e *ChecksumLinkIDEncoder) luhnSum(str string, factor int) (int, bool) {
+	n := int(e.alphabet.base())
+	sum := 0
+	for i := len(str) - 1; i >= 0; i-- {
+		digit := e.alphabet.index[str[i]]
+		if digit == -1 {
+			return 0, false
+		}
+
+		addend := factor * digit
+		sum += addend/n + addend%n
+		factor = 3 - factor
+	}
+	return sum, true
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksumLinkIDEncoder_RoundTrip(t *testing.T) {
+	encoder, err := NewChecksumLinkIDEncoder(NewZBase32LinkIDEncoder(), ZBase32Alphabet)
+	require.NoError(t, err)
+
+	for _, id := range []model.LinkID{0, 1, 42, model.LegacyMaxLinkID, model.LegacyMaxLinkID + 1, model.MaxLinkID} {
+		code, err := encoder.EncodeToString(id)
+		require.NoError(t, err)
+
+		inner, err := NewZBase32LinkIDEncoder().EncodeToString(id)
+		require.NoError(t, err)
+		assert.Equal(t, inner, code[:len(code)-1])
+
+		got, err := encoder.DecodeFromString(code)
+		require.NoError(t, err)
+		assert.Equal(t, id, got)
+	}
+}
+
+func TestChecksumLinkIDEncoder_DetectsTypos(t *testing.T) {
+	encoder, err := NewChecksumLinkIDEncoder(NewBase62LinkIDEncoder(), Base62Alphabet)
+	require.NoError(t, err)
+
+	code, err := encoder.EncodeToString(123456789)
+	require.NoError(t, err)
+
+	// Любая замена одного символа.
+	for i := 0; i < len(code); i++ {
+		for j := 0; j < len(Base62Alphabet); j++ {
+			if Base62Alphabet[j] == code[i] {
+				continue
+			}
+			typo := code[:i] + string(Base62Alphabet[j]) + code[i+1:]
+			_, err := encoder.DecodeFromString(typo)
+			require.ErrorIs(t, err, model.ErrInvalidChecksum, typo)
+		}
+	}
+
+	// Перестановка соседних символов.
+	for i := 0; i+1 < len(code); i++ {
+		if code[i] == code[i+1] {
+			continue
+		}
+		typo := code[:i] + string(code[i+1]) + string(code[i]) + code[i+2:]
+		_, err := encoder.DecodeFromString(typo)
+		assert.ErrorIs(t, err, model.ErrInvalidChecksum, typo)
+	}
+}
+
+func TestChecksumLinkIDEncoder_DecodeFromString(t *testing.T) {
+	encoder, err := NewChecksumLinkIDEncoder(NewZBase32LinkIDEncoder(), ZBase32Alphabet)
+	require.NoError(t, err)
+
+	for _, str := range []string{"", "y", "[][]", "yyyyyyy-"} {
+		_, err := encoder.DecodeFromString(str)
+		assert.ErrorIs(t, err, model.ErrDecodingShortURL, str)
+	}
+
+	// Код без контрольного символа принимается только через FallbackLinkIDEncoder.
+	legacy, err := NewZBase32LinkIDEncoder().EncodeToString(42)
+	require.NoError(t, err)
+	_, err = encoder.DecodeFromString(legacy)
+	assert.Error(t, err)
+
+	id, err := NewFallbackLinkIDEncoder(encoder, NewZBase32LinkIDEncoder()).DecodeFromString(legacy)
+	require.NoError(t, err)
+	assert.Equal(t, model.LinkID(42), id)
+}
+
+func TestFeistelLinkIDEncoder_ReserveLength(t *testing.T) {
+	encoder, err := NewFeistelLinkIDEncoder("secret")
+	require.NoError(t, err)
+	encoder.ReserveLength(ZBase32CodeLen - 1)
+
+	checked, err := NewChecksumLinkIDEncoder(encoder, Base62Alphabet)
+	require.NoError(t, err)
+
+	code, err := checked.EncodeToString(42)
+	require.NoError(t, err)
+	assert.NotEqual(t, ZBase32CodeLen, len(code))
+
+	id, err := checked.DecodeFromString(code)
+	require.NoError(t, err)
+	assert.Equal(t, model.LinkID(42), id)
+}
@@ -0,0 +1,79 @@
+package service
+
+import "github.com/ikashurnikov/shortener/internal/app/model"
+
+// FallbackLinkIDEncoder Кодирует ID ссылок основным кодировщиком,
+// а декодирует основным и, если код ему не подходит, предыдущими кодировщиками.
+// Позволяет сменить формат коротких ссылок, не ломая уже выданные.
+//
+// Предыдущие кодировщики декодируют только коды тех длин, которые основной кодировщик не выдает.
+// Иначе опечатка в новом коде, отвергнутая основным кодировщиком (например, по контрольному символу),
+// могла бы быть декодирована предыдущим и привести на чужую ссылку.
+type FallbackLinkIDEncoder struct {
+	primary        LinkIDEncoder
+	fallbacks      []LinkIDEncoder
+	primaryLengths map[int]bool
+}
+
+func NewFallbackLinkIDEncoder(primary LinkIDEncoder, fallbacks ...LinkIDEncoder) *FallbackLinkIDEncoder {
+	return &FallbackLinkIDEncoder{
+		primary:        primary,
+		fallbacks:      fallbacks,
+		primaryLengths: codeLengths(primary),
+	}
+}
+
+func (e *FallbackLinkIDEncoder) EncodeToString(linkID model.LinkID) (string, error) {
+	return e.primary.EncodeToString(linkID)
+}
+
+func (e *FallbackLinkIDEncoder) DecodeFromString(str string) (model.LinkID, error) {
+	linkID, err := e.primary.DecodeFromString(str)
+	if err == nil || e.primaryLengths[len(str)] {
+		return linkID, err
+	}
+
+	for _, fallback := range e.fallbacks {
+		if linkID, fallbackErr := fallback.DecodeFromString(str); fallbackErr == nil {
+			return linkID, nil
+		}
+	}
+	return 0, err
+}
+
+// NormalizeCode Приводит код к каноническому виду кодировщиком, который его декодирует.
+func (e *FallbackLinkIDEncoder) NormalizeCode(str string) string {
+	if _, err := e.primary.DecodeFromString(str); err == nil {
+		return e.primary.NormalizeCode(str)
+	}
+	if e.primaryLengths[len(str)] {
+		return str
+	}
+
+	for _, fallback := range e.fallbacks {
+		if _, err := fallback.DecodeFromString(str); err == nil {
+			return fallback.NormalizeCode(str)
+		}
+	}
+	return str
+}
+
+// codeLengths Возвращает длины кодов, которые выдает encoder. Длина кода зависит только от порядка
+// величины ID, а в каждом диапазоне ID одной длины есть степень двойки, поэтому достаточно
+// закодировать степени двойки и соседние с ними ID.
+func codeLengths(encoder LinkIDEncoder) map[int]bool {
+	lengths := make(map[int]bool)
+	add := func(linkID model.LinkID) {
+		if code, err := encoder.EncodeToString(linkID); err == nil {
+			lengths[len(code)] = true
+		}
+	}
+
+	add(0)
+	for shift := 0; shift < 63; shift++ {
+		add(model.LinkID(1) << shift)
+		add(model.LinkID(1)<<shift - 1)
+	}
+	add(model.MaxLinkID)
+	return lengths
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"math"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+)
+
+const feistelRounds = 8
+
+// FeistelLinkIDEncoder Кодирует ID ссылки в непоследовательный код.
+//
+// ID переставляется сетью Фейстеля с раундовыми ключами, полученными из секрета,
+// и записывается строкой фиксированной длины в алфавите base62, перемешанном тем же секретом.
+// Без секрета по коду нельзя восстановить ID и получить соседние коды.
+//
+// ID, не превосходящие model.LegacyMaxLinkID, переставляются в 32-битном диапазоне и записываются
+// 6 символами, как и до перехода на 64-битные ID, остальные - в 64-битном диапазоне 11 символами.
+// Длины, зарезервированные ReserveLength, заменяются ближайшей большей свободной длиной.
+type FeistelLinkIDEncoder struct {
+	roundKeys [feistelRounds]uint64
+	alphabet  *alphabet
+	width32   int
+	width64   int
+}
+
+func NewFeistelLinkIDEncoder(secret string) (*FeistelLinkIDEncoder, error) {
+	if secret == "" {
+		return nil, model.ErrInvalidSecret
+	}
+
+	base, err := newAlphabet(Base62Alphabet)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &FeistelLinkIDEncoder{
+		alphabet: base.shuffled([]byte(secret)),
+		width32:  base.width(math.MaxUint32),
+		width64:  base.width(math.MaxUint64),
+	}
+
+	rnd := newKeyStream([]byte(secret), "feistel")
+	for i := range e.roundKeys {
+		e.roundKeys[i] = rnd.next()
+	}
+	return e, nil
+}
+
+// ReserveLength Запрещает коды длины n.
+func (e *FeistelLinkIDEncoder) ReserveLength(n int) {
+	if e.width32 == n {
+		e.width32++
+	}
+	if e.width64 == n || e.width64 == e.width32 {
+		e.width64++
+	}
+}
+
+func (e *FeistelLinkIDEncoder) EncodeToString(linkID model.LinkID) (string, error) {
+	if linkID <= model.LegacyMaxLinkID {
+		return e.alphabet.encode(uint64(e.permute32(uint32(linkID))), e.width32), nil
+	}
+	return e.alphabet.encode(e.permute64(uint64(linkID)), e.width64), nil
+}
+
+func (e *FeistelLinkIDEncoder) DecodeFromString(str string) (model.LinkID, error) {
+	str = e.NormalizeCode(str)
+	switch len(str) {
+	case e.width32:
+		value, ok := e.alphabet.decode(str, math.MaxUint32)
+		if !ok {
+			return 0, model.ErrDecodingShortURL
+		}
+		return model.LinkID(e.unpermute32(uint32(value))), nil
+
+	case e.width64:
+		value, ok := e.alphabet.decode(str, math.MaxUint64)
+		if !ok {
+			return 0, model.ErrDecodingShortURL
+		}
+
+		linkID := model.LinkID(e.unpermute64(value))
+		if linkID <= model.LegacyMaxLinkID || linkID > model.MaxLinkID {
+			return 0, model.ErrDecodingShortURL
+		}
+		return linkID, nil
+	}
+
+	return 0, model.ErrDecodingShortURL
+}
+
+func (e *FeistelLinkIDEncoder) NormalizeCode(str string) string {
+	return e.alphabet.normalize(str)
+}
+
+func (e *FeistelLinkIDEncoder) permute32(value uint32) uint32 {
+	left, right := uint16(value>>16), uint16(value)
+	for _, key := range e.roundKeys {
+		left, right = right, left^uint16(e.round(key, uint64(right))>>48)
+	}
+	return uint32(left)<<16 | uint32(right)
+}
+
+func (e *FeistelLinkIDEncoder) unpermute32(value uint32) uint32 {
+	left, right := uint16(value>>16), uint16(value)
+	for i := len(e.roundKeys) - 1; i >= 0; i-- {
+		left, right = right^uint16(e.round(e.roundKeys[i], uint64(left))>>48), left
+	}
+	return uint32(left)<<16 | uint32(right)
+}
+
+func (e *FeistelLinkIDEncoder) permute64(value uint64) uint64 {
+	left, right := uint32(value>>32), uint32(value)
+	for _, key := range e.roundKeys {
+		left, right = right, left^uint32(e.round(key, uint64(right))>>32)
+	}
+	return uint64(left)<<32 | uint64(right)
+}
+
+func (e *FeistelLinkIDEncoder) unpermute64(value uint64) uint64 {
+	left, right := uint32(value>>32), uint32(value)
+	for i := len(e.roundKeys) - 1; i >= 0; i-- {
+		left, right = right^uint32(e.round(e.roundKeys[i], uint64(left))>>32), left
+	}
+	return uint64(left)<<32 | uint64(right)
+}
+
+// round Раундовая функция сети Фейстеля.
+func (e *FeistelLinkIDEncoder) round(key uint64, half uint64) uint64 {
+	return mix64(key ^ half)
+}
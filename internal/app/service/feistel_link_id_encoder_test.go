@@ -0,0 +1,102 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeistelLinkIDEncoder_RoundTrip(t *testing.T) {
+	encoder, err := NewFeistelLinkIDEncoder("secret")
+	require.NoError(t, err)
+
+	ids := []model.LinkID{0, 1, 2, 3, 1000, 0xffff, 0x10000, math.MaxUint32 - 1, math.MaxUint32,
+		math.MaxUint32 + 1, 1 << 40, model.MaxLinkID}
+	codes := make(map[string]model.LinkID)
+	for _, id := range ids {
+		code, err := encoder.EncodeToString(id)
+		require.NoError(t, err)
+		if id <= model.LegacyMaxLinkID {
+			assert.Len(t, code, 6)
+		} else {
+			assert.Len(t, code, 11)
+		}
+		assert.NotContains(t, codes, code)
+		codes[code] = id
+
+		got, err := encoder.DecodeFromString(code)
+		require.NoError(t, err)
+		assert.Equal(t, id, got)
+	}
+}
+
+func TestFeistelLinkIDEncoder_Secret(t *testing.T) {
+	_, err := NewFeistelLinkIDEncoder("")
+	require.ErrorIs(t, err, model.ErrInvalidSecret)
+
+	encoder1, err := NewFeistelLinkIDEncoder("secret1")
+	require.NoError(t, err)
+	encoder2, err := NewFeistelLinkIDEncoder("secret2")
+	require.NoError(t, err)
+
+	code1, err := encoder1.EncodeToString(1)
+	require.NoError(t, err)
+	code2, err := encoder2.EncodeToString(1)
+	require.NoError(t, err)
+	assert.NotEqual(t, code1, code2)
+
+	// Соседние ID не дают соседних кодов.
+	next, err := encoder1.EncodeToString(2)
+	require.NoError(t, err)
+	assert.NotEqual(t, code1[:5], next[:5])
+}
+
+func TestFeistelLinkIDEncoder_DecodeFromString(t *testing.T) {
+	encoder, err := NewFeistelLinkIDEncoder("secret")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		str  string
+	}{
+		{name: "too short string", str: "abcde"},
+		{name: "too long string", str: "abcdefg"},
+		{name: "64-bit code of 32-bit id", str: encoder.alphabet.encode(encoder.permute64(42), encoder.width64)},
+		{name: "invalid symbols", str: "abc-ef"},
+		{name: "value out of range", str: encoder.alphabet.encode(math.MaxUint32+1, encoder.width32)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := encoder.DecodeFromString(tt.str)
+			assert.ErrorIs(t, err, model.ErrDecodingShortURL)
+		})
+	}
+}
+
+func TestFallbackLinkIDEncoder(t *testing.T) {
+	feistel, err := NewFeistelLinkIDEncoder("secret")
+	require.NoError(t, err)
+	zbase32 := NewZBase32LinkIDEncoder()
+	encoder := NewFallbackLinkIDEncoder(feistel, zbase32)
+
+	code, err := encoder.EncodeToString(42)
+	require.NoError(t, err)
+	expected, err := feistel.EncodeToString(42)
+	require.NoError(t, err)
+	assert.Equal(t, expected, code)
+
+	legacy, err := zbase32.EncodeToString(42)
+	require.NoError(t, err)
+
+	for _, str := range []string{code, legacy} {
+		id, err := encoder.DecodeFromString(str)
+		require.NoError(t, err)
+		assert.Equal(t, model.LinkID(42), id)
+	}
+
+	_, err = encoder.DecodeFromString("[][]")
+	assert.ErrorIs(t, err, model.ErrDecodingShortURL)
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"net"
+	"strings"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoIP Определяет страну по IP-адресу по локальной базе в формате MaxMind DB
+// (GeoLite2-Country, GeoIP2-Country, GeoLite2-City и совместимые). Обращений к сети не требуется.
+type GeoIP struct {
+	reader *maxminddb.Reader
+}
+
+// geoIPRecord Поля записи базы, из которых берется страна. Если страна адреса неизвестна,
+// используется страна регистрации сети.
+type geoIPRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// NewGeoIP Открывает базу filename.
+func NewGeoIP(filename string) (*GeoIP, error) {
+	reader, err := maxminddb.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &GeoIP{reader: reader}, nil
+}
+
+// Country Возвращает ISO-код страны адреса ip в верхнем регистре. Пустая строка, если адреса нет в базе.
+func (g *GeoIP) Country(ip net.IP) (string, error) {
+	var record geoIPRecord
+	if err := g.reader.Lookup(ip, &record); err != nil {
+		return "", err
+	}
+
+	country := record.Country.ISOCode
+	if country == "" {
+		country = record.RegisteredCountry.ISOCode
+	}
+	country = strings.ToUpper(country)
+	if !model.IsCountryCode(country) {
+		return "", nil
+	}
+	return country, nil
+}
+
+func (g *GeoIP) Close() error {
+	return g.reader.Close()
+}
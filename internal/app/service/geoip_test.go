@@ -0,0 +1,174 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestGeoIPDatabase Записывает базу IPv4 в формате MaxMind DB, в которой сетям networks
+// соответствуют ISO-коды стран.
+func writeTestGeoIPDatabase(t *testing.T, networks map[string]string) string {
+	const empty = -1
+
+	// Узел дерева хранит для битов 0 и 1 индекс следующего узла, empty или -(смещение данных + 2).
+	nodes := [][2]int{{empty, empty}}
+	var data bytes.Buffer
+	for cidr, country := range networks {
+		_, network, err := net.ParseCIDR(cidr)
+		require.NoError(t, err)
+		ones, _ := network.Mask.Size()
+		ip := network.IP.To4()
+
+		record := -(data.Len() + 2)
+		writeMMDBMap(&data, 1)
+		writeMMDBString(&data, "country")
+		writeMMDBMap(&data, 1)
+		writeMMDBString(&data, "iso_code")
+		writeMMDBString(&data, country)
+
+		node := 0
+		for i := 0; i < ones; i++ {
+			bit := (ip[i/8] >> (7 - i%8)) & 1
+			if i == ones-1 {
+				nodes[node][bit] = record
+				break
+			}
+			if nodes[node][bit] == empty {
+				nodes = append(nodes, [2]int{empty, empty})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+
+	var db bytes.Buffer
+	nodeCount := len(nodes)
+	for _, node := range nodes {
+		for _, record := range node {
+			value := record
+			switch {
+			case record == empty:
+				value = nodeCount
+			case record < 0:
+				value = nodeCount + 16 + (-record - 2)
+			}
+			db.Write([]byte{byte(value >> 16), byte(value >> 8), byte(value)})
+		}
+	}
+	db.Write(make([]byte, 16))
+	db.Write(data.Bytes())
+
+	db.WriteString("\xab\xcd\xefMaxMind.com")
+	writeMMDBMap(&db, 9)
+	writeMMDBString(&db, "binary_format_major_version")
+	writeMMDBUint(&db, 5, 2, 2)
+	writeMMDBString(&db, "binary_format_minor_version")
+	writeMMDBUint(&db, 5, 0, 2)
+	writeMMDBString(&db, "build_epoch")
+	db.Write([]byte{8, 9 - 7})
+	_ = binary.Write(&db, binary.BigEndian, uint64(0))
+	writeMMDBString(&db, "database_type")
+	writeMMDBString(&db, "Test-Country")
+	writeMMDBString(&db, "description")
+	writeMMDBMap(&db, 0)
+	writeMMDBString(&db, "ip_version")
+	writeMMDBUint(&db, 5, 4, 2)
+	writeMMDBString(&db, "languages")
+	db.Write([]byte{0, 11 - 7})
+	writeMMDBString(&db, "node_count")
+	writeMMDBUint(&db, 6, uint64(nodeCount), 4)
+	writeMMDBString(&db, "record_size")
+	writeMMDBUint(&db, 5, 24, 2)
+
+	filename := filepath.Join(t.TempDir(), "country.mmdb")
+	require.NoError(t, os.WriteFile(filename, db.Bytes(), 0o600))
+	return filename
+}
+
+func writeMMDBMap(buf *bytes.Buffer, size int) {
+	buf.WriteByte(7<<5 | byte(size))
+}
+
+func writeMMDBString(buf *bytes.Buffer, s string) {
+	buf.WriteByte(2<<5 | byte(len(s)))
+	buf.WriteString(s)
+}
+
+func writeMMDBUint(buf *bytes.Buffer, typ byte, value uint64, size int) {
+	buf.WriteByte(typ<<5 | byte(size))
+	for i := size - 1; i >= 0; i-- {
+		buf.WriteByte(byte(value >> (8 * i)))
+	}
+}
+
+func TestGeoIP_Country(t *testing.T) {
+	geoIP, err := NewGeoIP(writeTestGeoIPDatabase(t, map[string]string{
+		"81.2.69.0/24": "gb",
+		"2.125.0.0/16": "DE",
+	}))
+	require.NoError(t, err)
+	defer geoIP.Close()
+
+	tests := []struct {
+		ip      string
+		country string
+	}{
+		{ip: "81.2.69.142", country: "GB"},
+		{ip: "2.125.160.216", country: "DE"},
+		{ip: "8.8.8.8"},
+	}
+	for _, tt := range tests {
+		country, err := geoIP.Country(net.ParseIP(tt.ip))
+		require.NoError(t, err, tt.ip)
+		assert.Equal(t, tt.country, country, tt.ip)
+	}
+
+	_, err = geoIP.Country(net.ParseIP("2001:db8::1"))
+	assert.Error(t, err)
+
+	_, err = NewGeoIP(filepath.Join(t.TempDir(), "missing.mmdb"))
+	assert.Error(t, err)
+}
+
+func TestShortener_GeoRouting(t *testing.T) {
+	geoIP, err := NewGeoIP(writeTestGeoIPDatabase(t, map[string]string{"81.2.69.0/24": "GB"}))
+	require.NoError(t, err)
+	defer geoIP.Close()
+
+	s := newTestShortener(t, WithGeoIP(geoIP))
+
+	userID := model.UserID(model.InvalidUserID)
+	_, code := createCode(t, s, &userID, "https://shop.example/")
+
+	routing := model.RoutingRules{{Countries: []string{"gb", "IE"}, OriginalURL: "https://shop.example/uk"}}
+	_, err = s.UpdateLink(userID, code, model.LinkPatch{Routing: &routing})
+	require.NoError(t, err)
+
+	got, err := s.GetLinkByShortURL(code, model.Visit{IP: net.ParseIP("81.2.69.142")})
+	require.NoError(t, err)
+	assert.Equal(t, "https://shop.example/uk", got.OriginalURL)
+	assert.Equal(t, "GB", got.Country)
+	require.NoError(t, s.CountClick(code, model.Click{Country: got.Country}))
+
+	// Страна не определена: правило со странами не выполняется.
+	for _, ip := range []string{"8.8.8.8", "2001:db8::1"} {
+		got, err = s.GetLinkByShortURL(code, model.Visit{IP: net.ParseIP(ip)})
+		require.NoError(t, err)
+		assert.Equal(t, "https://shop.example/", got.OriginalURL)
+		assert.Empty(t, got.Country)
+		require.NoError(t, s.CountClick(code, model.Click{Country: got.Country}))
+	}
+
+	got, err = s.GetLinkByShortURL(code, model.Visit{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), got.Clicks)
+	assert.Equal(t, map[string]int64{"GB": 1}, got.CountryClicks)
+}
@@ -0,0 +1,94 @@
+package service
+
+import "github.com/ikashurnikov/shortener/internal/app/model"
+
+// LinkIDEncoderConfig Формат коротких ссылок.
+type LinkIDEncoderConfig struct {
+	// Format zbase32 (последовательные коды), feistel (непоследовательные коды) или base62 (коды минимальной длины).
+	Format string
+	// Alphabet Алфавит кодов в формате base62.
+	Alphabet string
+	// Secret Секрет, которым перемешиваются коды в формате feistel.
+	Secret string
+	// Checksum Добавлять к кодам контрольный символ.
+	Checksum bool
+	// AcceptUnchecked Принимать коды без контрольного символа, выданные до включения Checksum.
+	// Недоступно для форматов, у которых коды без контрольного символа совпадают по длине с кодами
+	// с контрольным символом (base62): опечатка в новом коде декодировалась бы как старый код.
+	AcceptUnchecked bool
+}
+
+// NewLinkIDEncoder Создает кодировщик ID ссылок формата cfg. Ранее выданные коды zbase32 продолжают работать.
+// Возвращает ErrAmbiguousUnchecked, если коды без контрольного символа формата cfg.Format нельзя отличить
+// от кодов с контрольным символом по длине.
+func NewLinkIDEncoder(cfg LinkIDEncoderConfig) (LinkIDEncoder, error) {
+	zbase32 := NewZBase32LinkIDEncoder()
+	if cfg.Format == "zbase32" && !cfg.Checksum {
+		return zbase32, nil
+	}
+
+	// Новые коды не должны совпадать по длине с кодами zbase32.
+	// Контрольный символ удлиняет код на один символ.
+	reservedLen := ZBase32CodeLen
+	if cfg.Checksum {
+		reservedLen--
+	}
+	encoder, symbols, err := newFormatLinkIDEncoder(cfg, zbase32, reservedLen)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.Checksum {
+		return NewFallbackLinkIDEncoder(encoder, zbase32), nil
+	}
+
+	checked, err := NewChecksumLinkIDEncoder(encoder, symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.AcceptUnchecked {
+		return checked, nil
+	}
+
+	// Коды, выданные до включения контрольного символа.
+	fallbacks := []LinkIDEncoder{zbase32}
+	if cfg.Format != "zbase32" {
+		unchecked, _, _ := newFormatLinkIDEncoder(cfg, zbase32, ZBase32CodeLen)
+		checkedLengths := codeLengths(checked)
+		for n := range codeLengths(unchecked) {
+			if checkedLengths[n] {
+				return nil, model.ErrAmbiguousUnchecked
+			}
+		}
+		fallbacks = append(fallbacks, unchecked)
+	}
+	return NewFallbackLinkIDEncoder(checked, fallbacks...), nil
+}
+
+// newFormatLinkIDEncoder Создает кодировщик формата cfg.Format, не выдающий коды длины reservedLen,
+// и возвращает его вместе с алфавитом кодов.
+func newFormatLinkIDEncoder(cfg LinkIDEncoderConfig, zbase32 LinkIDEncoder, reservedLen int) (LinkIDEncoder, string, error) {
+	switch cfg.Format {
+	case "zbase32":
+		return zbase32, ZBase32Alphabet, nil
+
+	case "feistel":
+		feistel, err := NewFeistelLinkIDEncoder(cfg.Secret)
+		if err != nil {
+			return nil, "", err
+		}
+		feistel.ReserveLength(reservedLen)
+		return feistel, Base62Alphabet, nil
+
+	case "base62":
+		base62, err := NewBaseNLinkIDEncoder(cfg.Alphabet)
+		if err != nil {
+			return nil, "", err
+		}
+		base62.ReserveLength(reservedLen)
+		return base62, cfg.Alphabet, nil
+	}
+
+	return nil, "", model.ErrUnknownLinkIDFormat
+}
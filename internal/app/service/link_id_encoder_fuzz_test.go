@@ -0,0 +1,170 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+)
+
+// fuzzEncoders Возвращает все кодировщики ID ссылок в типичных конфигурациях.
+func fuzzEncoders(t *testing.T) map[string]LinkIDEncoder {
+	zbase32 := NewZBase32LinkIDEncoder()
+
+	base62 := NewBase62LinkIDEncoder()
+	base62.ReserveLength(ZBase32CodeLen)
+
+	feistel, err := NewFeistelLinkIDEncoder("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	feistel.ReserveLength(ZBase32CodeLen)
+
+	checkedFeistel, err := NewFeistelLinkIDEncoder("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkedFeistel.ReserveLength(ZBase32CodeLen - 1)
+
+	checksum, err := NewChecksumLinkIDEncoder(checkedFeistel, Base62Alphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkedZBase32, err := NewChecksumLinkIDEncoder(zbase32, ZBase32Alphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoders := map[string]LinkIDEncoder{
+		"zbase32":          zbase32,
+		"base62":           base62,
+		"feistel":          feistel,
+		"checksum":         checksum,
+		"checksum-zbase32": checkedZBase32,
+		"fallback":         NewFallbackLinkIDEncoder(checksum, zbase32, feistel),
+	}
+
+	// Конфигурации, которые создает сервис.
+	for name, cfg := range fuzzEncoderConfigs() {
+		encoder, err := NewLinkIDEncoder(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoders["config-"+name] = encoder
+	}
+	return encoders
+}
+
+// fuzzEncoderConfigs Возвращает все допустимые сочетания форматов и настроек контрольного символа.
+func fuzzEncoderConfigs() map[string]LinkIDEncoderConfig {
+	configs := make(map[string]LinkIDEncoderConfig)
+	for _, format := range []string{"zbase32", "feistel", "base62"} {
+		cfg := LinkIDEncoderConfig{Format: format, Alphabet: Base62Alphabet, Secret: "secret"}
+		configs[format] = cfg
+
+		cfg.Checksum = true
+		configs[format+"-checksum"] = cfg
+
+		if format != "base62" {
+			cfg.AcceptUnchecked = true
+			configs[format+"-checksum-unchecked"] = cfg
+		}
+	}
+	return configs
+}
+
+// FuzzLinkIDEncoder_RoundTrip Начальный корпус - testdata/fuzz/FuzzLinkIDEncoder_RoundTrip.
+func FuzzLinkIDEncoder_RoundTrip(f *testing.F) {
+	f.Fuzz(func(t *testing.T, value uint64) {
+		linkID := model.LinkID(value % (uint64(model.MaxLinkID) + 1))
+
+		for name, encoder := range fuzzEncoders(t) {
+			code, err := encoder.EncodeToString(linkID)
+			if err != nil {
+				t.Fatalf("%s: encoding %d: %v", name, linkID, err)
+			}
+
+			if normalized := encoder.NormalizeCode(code); normalized != code {
+				t.Fatalf("%s: code %q of %d is not canonical: %q", name, code, linkID, normalized)
+			}
+
+			got, err := encoder.DecodeFromString(code)
+			if err != nil {
+				t.Fatalf("%s: decoding %q: %v", name, code, err)
+			}
+			if got != linkID {
+				t.Fatalf("%s: %d -> %q -> %d", name, linkID, code, got)
+			}
+		}
+	})
+}
+
+// FuzzLinkIDEncoder_DecodeFromString Начальный корпус - testdata/fuzz/FuzzLinkIDEncoder_DecodeFromString.
+func FuzzLinkIDEncoder_DecodeFromString(f *testing.F) {
+	f.Fuzz(func(t *testing.T, str string) {
+		for name, encoder := range fuzzEncoders(t) {
+			normalized := encoder.NormalizeCode(str)
+			if again := encoder.NormalizeCode(normalized); again != normalized {
+				t.Fatalf("%s: normalization is not idempotent: %q -> %q -> %q", name, str, normalized, again)
+			}
+
+			linkID, err := encoder.DecodeFromString(str)
+			if err != nil {
+				continue
+			}
+
+			if linkID > model.MaxLinkID {
+				t.Fatalf("%s: %q decoded to id out of range %d", name, str, linkID)
+			}
+
+			code, err := encoder.EncodeToString(linkID)
+			if err != nil {
+				t.Fatalf("%s: encoding %d: %v", name, linkID, err)
+			}
+
+			got, err := encoder.DecodeFromString(code)
+			if err != nil || got != linkID {
+				t.Fatalf("%s: %q -> %d -> %q -> %d, %v", name, str, linkID, code, got, err)
+			}
+		}
+	})
+}
+
+// FuzzLinkIDEncoder_Typo Проверяет, что кодировщики с контрольным символом отвергают замену одного символа кода.
+// Начальный корпус - testdata/fuzz/FuzzLinkIDEncoder_Typo.
+func FuzzLinkIDEncoder_Typo(f *testing.F) {
+	f.Fuzz(func(t *testing.T, value uint64, pos uint8, symbol uint8) {
+		linkID := model.LinkID(value % (uint64(model.MaxLinkID) + 1))
+
+		for name, cfg := range fuzzEncoderConfigs() {
+			if !cfg.Checksum {
+				continue
+			}
+
+			encoder, err := NewLinkIDEncoder(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			code, err := encoder.EncodeToString(linkID)
+			if err != nil {
+				t.Fatalf("%s: encoding %d: %v", name, linkID, err)
+			}
+
+			symbols := cfg.Alphabet
+			if cfg.Format == "zbase32" {
+				symbols = ZBase32Alphabet
+			}
+			i := int(pos) % len(code)
+			typo := code[:i] + string(symbols[int(symbol)%len(symbols)]) + code[i+1:]
+			if encoder.NormalizeCode(typo) == code {
+				continue
+			}
+
+			if got, err := encoder.DecodeFromString(typo); !errors.Is(err, model.ErrInvalidChecksum) {
+				t.Fatalf("%s: typo %q of %q (%d) decoded to %d, %v", name, typo, code, linkID, got, err)
+			}
+		}
+	})
+}
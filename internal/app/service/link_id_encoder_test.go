@@ -0,0 +1,76 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLinkIDEncoder_DetectsTypos(t *testing.T) {
+	formats := map[string]string{"zbase32": ZBase32Alphabet, "feistel": Base62Alphabet, "base62": Base62Alphabet}
+	for format, symbols := range formats {
+		encoder, err := NewLinkIDEncoder(LinkIDEncoderConfig{
+			Format:          format,
+			Alphabet:        Base62Alphabet,
+			Secret:          "secret",
+			Checksum:        true,
+			AcceptUnchecked: format != "base62",
+		})
+		require.NoError(t, err)
+
+		for _, id := range []model.LinkID{0, 1, 61, 184, 123456789, model.LegacyMaxLinkID + 1} {
+			code, err := encoder.EncodeToString(id)
+			require.NoError(t, err)
+
+			// Любая замена одного символа.
+			for i := 0; i < len(code); i++ {
+				for j := 0; j < len(symbols); j++ {
+					typo := code[:i] + string(symbols[j]) + code[i+1:]
+					if encoder.NormalizeCode(typo) == code {
+						continue
+					}
+					_, err := encoder.DecodeFromString(typo)
+					require.ErrorIs(t, err, model.ErrInvalidChecksum, "%s: %q", format, typo)
+				}
+			}
+		}
+	}
+}
+
+func TestNewLinkIDEncoder_AcceptUnchecked(t *testing.T) {
+	legacy, err := NewZBase32LinkIDEncoder().EncodeToString(42)
+	require.NoError(t, err)
+
+	feistel, err := NewFeistelLinkIDEncoder("secret")
+	require.NoError(t, err)
+	feistel.ReserveLength(ZBase32CodeLen)
+	unchecked, err := feistel.EncodeToString(42)
+	require.NoError(t, err)
+
+	cfg := LinkIDEncoderConfig{Format: "feistel", Secret: "secret", Checksum: true, AcceptUnchecked: true}
+	encoder, err := NewLinkIDEncoder(cfg)
+	require.NoError(t, err)
+	for _, code := range []string{legacy, unchecked} {
+		id, err := encoder.DecodeFromString(code)
+		require.NoError(t, err, code)
+		assert.Equal(t, model.LinkID(42), id)
+	}
+
+	cfg.AcceptUnchecked = false
+	encoder, err = NewLinkIDEncoder(cfg)
+	require.NoError(t, err)
+	for _, code := range []string{legacy, unchecked} {
+		_, err := encoder.DecodeFromString(code)
+		assert.Error(t, err, code)
+	}
+
+	// Коды base62 без контрольного символа не отличить от кодов с ним.
+	cfg = LinkIDEncoderConfig{Format: "base62", Alphabet: Base62Alphabet, Checksum: true, AcceptUnchecked: true}
+	_, err = NewLinkIDEncoder(cfg)
+	assert.ErrorIs(t, err, model.ErrAmbiguousUnchecked)
+
+	_, err = NewLinkIDEncoder(LinkIDEncoderConfig{Format: "base64"})
+	assert.ErrorIs(t, err, model.ErrUnknownLinkIDFormat)
+}
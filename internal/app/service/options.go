@@ -0,0 +1,81 @@
+package service
+
+import "github.com/ikashurnikov/shortener/internal/app/model"
+
+// Option Настройка сервиса сокращения ссылок.
+type Option func(*shortener)
+
+// WithLinkIDEncoder Задает кодировщик ID ссылок. По умолчанию ZBase32LinkIDEncoder.
+func WithLinkIDEncoder(encoder LinkIDEncoder) Option {
+	return func(s *shortener) {
+		s.linkIDEncoder = encoder
+	}
+}
+
+// WithURLCanonicalization Задает правила приведения оригинальных ссылок к каноническому виду.
+// По умолчанию model.DefaultURLCanonicalization.
+func WithURLCanonicalization(canonicalization model.URLCanonicalization) Option {
+	return func(s *shortener) {
+		s.canonicalizer = canonicalization
+	}
+}
+
+// WithRewriteRules Задает правила удаления параметров при сокращении ссылок и добавления параметров при переходе.
+func WithRewriteRules(rules model.RewriteRules) Option {
+	return func(s *shortener) {
+		s.rewriteRules = rules
+	}
+}
+
+// WithDestinationPolicy Задает ограничения на оригинальные ссылки. По умолчанию model.DefaultDestinationPolicy.
+func WithDestinationPolicy(policy model.DestinationPolicy) Option {
+	return func(s *shortener) {
+		s.destinations = policy
+	}
+}
+
+// WithURLResolver Задает раскрытие ссылок других сокращателей. По умолчанию ссылки не раскрываются.
+func WithURLResolver(resolver URLResolver) Option {
+	return func(s *shortener) {
+		s.resolver = resolver
+	}
+}
+
+// WithRejectSelfLinks Отклонять короткие ссылки сервиса вместо замены их оригинальными ссылками.
+func WithRejectSelfLinks(reject bool) Option {
+	return func(s *shortener) {
+		s.rejectSelfLinks = reject
+	}
+}
+
+// WithBlocklist Задает список фишинговых и вредоносных адресов: ссылки на них не сокращаются,
+// а переход по сохраненным ранее ссылкам блокируется.
+func WithBlocklist(blocklist *Blocklist) Option {
+	return func(s *shortener) {
+		s.blocklist = blocklist
+	}
+}
+
+// WithGeoIP Задает базу, по которой определяется страна посетителя для правил перехода по ссылке
+// и статистики переходов. По умолчанию страна не определяется.
+func WithGeoIP(geoIP *GeoIP) Option {
+	return func(s *shortener) {
+		s.geoIP = geoIP
+	}
+}
+
+// WithQRCodeRenderer Задает генератор QR-кодов коротких ссылок.
+// По умолчанию QRCodeRenderer с кешем на DefaultQRCodeCacheSize изображений.
+func WithQRCodeRenderer(renderer *QRCodeRenderer) Option {
+	return func(s *shortener) {
+		s.qrCodes = renderer
+	}
+}
+
+// WithDefaultRedirectStatus Задает статус перенаправления для ссылок, у которых он не задан:
+// 301, 302, 307 или 308. По умолчанию 307.
+func WithDefaultRedirectStatus(status int) Option {
+	return func(s *shortener) {
+		s.redirectStatus = status
+	}
+}
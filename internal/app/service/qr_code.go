@@ -0,0 +1,176 @@
+package service
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"sync"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+	"github.com/skip2/go-qrcode"
+)
+
+// DefaultQRCodeCacheSize Кол-во изображений QR-кодов, хранящихся в кеше по умолчанию.
+const DefaultQRCodeCacheSize = 1024
+
+// QRCodeRenderer Рисует QR-коды в форматах PNG и SVG и хранит последние нарисованные изображения в кеше.
+type QRCodeRenderer struct {
+	guard     sync.Mutex
+	cacheSize int
+	// cache Изображения в порядке использования, от последнего к давнему.
+	cache *list.List
+	items map[qrCodeKey]*list.Element
+}
+
+type qrCodeKey struct {
+	content string
+	opts    model.QRCodeOptions
+}
+
+type qrCodeEntry struct {
+	key  qrCodeKey
+	data []byte
+}
+
+// NewQRCodeRenderer Создает QRCodeRenderer, хранящий в кеше до cacheSize изображений. 0 отключает кеш.
+func NewQRCodeRenderer(cacheSize int) *QRCodeRenderer {
+	return &QRCodeRenderer{
+		cacheSize: cacheSize,
+		cache:     list.New(),
+		items:     make(map[qrCodeKey]*list.Element),
+	}
+}
+
+// Render Возвращает изображение QR-кода, содержащего content.
+func (r *QRCodeRenderer) Render(content string, opts model.QRCodeOptions) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	key := qrCodeKey{content: content, opts: opts}
+	if data, ok := r.get(key); ok {
+		return data, nil
+	}
+
+	data, err := r.render(content, opts)
+	if err != nil {
+		return nil, err
+	}
+	r.put(key, data)
+	return data, nil
+}
+
+func (r *QRCodeRenderer) render(content string, opts model.QRCodeOptions) ([]byte, error) {
+	code, err := qrcode.New(content, qrCodeRecoveryLevel(opts.Level))
+	if err != nil {
+		return nil, err
+	}
+	code.DisableBorder = true
+	bitmap := code.Bitmap()
+
+	if opts.Format == model.QRCodeSVG {
+		return renderQRCodeSVG(bitmap, opts), nil
+	}
+	return renderQRCodePNG(bitmap, opts)
+}
+
+func (r *QRCodeRenderer) get(key qrCodeKey) ([]byte, bool) {
+	r.guard.Lock()
+	defer r.guard.Unlock()
+
+	elem, ok := r.items[key]
+	if !ok {
+		return nil, false
+	}
+	r.cache.MoveToFront(elem)
+	return elem.Value.(*qrCodeEntry).data, true
+}
+
+func (r *QRCodeRenderer) put(key qrCodeKey, data []byte) {
+	r.guard.Lock()
+	defer r.guard.Unlock()
+
+	if r.cacheSize <= 0 {
+		return
+	}
+	if elem, ok := r.items[key]; ok {
+		r.cache.MoveToFront(elem)
+		return
+	}
+
+	r.items[key] = r.cache.PushFront(&qrCodeEntry{key: key, data: data})
+	if r.cache.Len() > r.cacheSize {
+		oldest := r.cache.Back()
+		r.cache.Remove(oldest)
+		delete(r.items, oldest.Value.(*qrCodeEntry).key)
+	}
+}
+
+func qrCodeRecoveryLevel(level model.QRCodeLevel) qrcode.RecoveryLevel {
+	switch level {
+	case model.QRCodeLevelL:
+		return qrcode.Low
+	case model.QRCodeLevelQ:
+		return qrcode.High
+	case model.QRCodeLevelH:
+		return qrcode.Highest
+	default:
+		return qrcode.Medium
+	}
+}
+
+// renderQRCodePNG Рисует код целым числом пикселей на модуль, чтобы края модулей оставались четкими.
+// Остаток размера распределяется по краям изображения.
+func renderQRCodePNG(bitmap [][]bool, opts model.QRCodeOptions) ([]byte, error) {
+	modules := len(bitmap) + 2*opts.Margin
+	scale := opts.Size / modules
+	if scale == 0 {
+		return nil, model.ErrInvalidQRCodeOptions
+	}
+	offset := (opts.Size - scale*modules) / 2
+
+	palette := color.Palette{opts.Background, opts.Foreground}
+	img := image.NewPaletted(image.Rect(0, 0, opts.Size, opts.Size), palette)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			left := offset + (x+opts.Margin)*scale
+			top := offset + (y+opts.Margin)*scale
+			for py := top; py < top+scale; py++ {
+				for px := left; px < left+scale; px++ {
+					img.SetColorIndex(px, py, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func renderQRCodeSVG(bitmap [][]bool, opts model.QRCodeOptions) []byte {
+	modules := len(bitmap) + 2*opts.Margin
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"/>`, modules, modules, model.HexColor(opts.Background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, model.HexColor(opts.Foreground))
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x+opts.Margin, y+opts.Margin)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+	"github.com/skip2/go-qrcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testQRCodeContent = "http://short.example/yyyyyyy"
+
+// testQRCodeModules Возвращает кол-во модулей кода testQRCodeContent без рамки.
+func testQRCodeModules(t *testing.T) int {
+	code, err := qrcode.New(testQRCodeContent, qrcode.Medium)
+	require.NoError(t, err)
+	code.DisableBorder = true
+	return len(code.Bitmap())
+}
+
+func TestQRCodeRenderer_PNG(t *testing.T) {
+	renderer := NewQRCodeRenderer(0)
+
+	opts := model.DefaultQRCodeOptions(model.QRCodePNG)
+	opts.Size = 100
+	opts.Foreground = color.RGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xff}
+
+	data, err := renderer.Render(testQRCodeContent, opts)
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 100, img.Bounds().Dx())
+	assert.Equal(t, 100, img.Bounds().Dy())
+
+	// Модуль занимает целое число пикселей, остаток делится поровну между краями.
+	modules := testQRCodeModules(t) + 2*opts.Margin
+	scale := opts.Size / modules
+	offset := (opts.Size - scale*modules) / 2
+
+	r, g, b, _ := img.At(offset+opts.Margin*scale-1, offset+opts.Margin*scale-1).RGBA()
+	assert.Equal(t, []uint32{0xffff, 0xffff, 0xffff}, []uint32{r, g, b})
+	// Левый верхний угол кода - темный модуль поискового узора.
+	r, g, b, _ = img.At(offset+opts.Margin*scale, offset+opts.Margin*scale).RGBA()
+	assert.Equal(t, []uint32{0x1111, 0x2222, 0x3333}, []uint32{r, g, b})
+
+	opts.Size = model.MinQRCodeSize
+	_, err = renderer.Render(strings.Repeat("x", 200), opts)
+	assert.ErrorIs(t, err, model.ErrInvalidQRCodeOptions)
+}
+
+func TestQRCodeRenderer_SVG(t *testing.T) {
+	opts := model.DefaultQRCodeOptions(model.QRCodeSVG)
+	opts.Margin = 0
+	opts.Background, _ = model.ParseHexColor("ffc")
+
+	data, err := NewQRCodeRenderer(0).Render(testQRCodeContent, opts)
+	require.NoError(t, err)
+
+	modules := testQRCodeModules(t)
+	svg := string(data)
+	assert.True(t, strings.HasPrefix(svg, fmt.Sprintf(
+		`<svg xmlns="http://www.w3.org/2000/svg" width="256" height="256" viewBox="0 0 %d %d"`, modules, modules)))
+	assert.Contains(t, svg, fmt.Sprintf(`<rect width="%d" height="%d" fill="#ffffcc"/>`, modules, modules))
+	assert.Contains(t, svg, `<path fill="#000000" d="M0 0h1v1h-1z`)
+}
+
+func TestQRCodeRenderer_Cache(t *testing.T) {
+	renderer := NewQRCodeRenderer(1)
+	opts := model.DefaultQRCodeOptions(model.QRCodeSVG)
+
+	first, err := renderer.Render("a", opts)
+	require.NoError(t, err)
+	cached, err := renderer.Render("a", opts)
+	require.NoError(t, err)
+	assert.Same(t, &first[0], &cached[0])
+
+	_, err = renderer.Render("b", opts)
+	require.NoError(t, err)
+	evicted, err := renderer.Render("a", opts)
+	require.NoError(t, err)
+	assert.Equal(t, first, evicted)
+	assert.NotSame(t, &first[0], &evicted[0])
+
+	opts.Level = "X"
+	_, err = renderer.Render("a", opts)
+	assert.ErrorIs(t, err, model.ErrInvalidQRCodeOptions)
+}
@@ -2,16 +2,36 @@ package service
 
 import "github.com/ikashurnikov/shortener/internal/app/model"
 
+const (
+	// DefaultPageSize Кол-во ссылок пользователя на странице, если размер страницы не задан.
+	DefaultPageSize = 100
+	// MaxPageSize Максимальное кол-во ссылок пользователя на странице.
+	MaxPageSize = 1000
+)
+
 type Shortener interface {
 	CreateLink(userID *model.UserID, originalURL string) (model.Link, error)
 	CreateLinks(userID *model.UserID, originalURLs []string) ([]model.Link, error)
-	GetLinkByShortURL(shortURL string) (model.Link, error)
-	GetLinksByUserID(id model.UserID) ([]model.Link, error)
+	GetLinkByShortURL(shortURL string, visit model.Visit) (model.Link, error)
+	CountClick(shortURL string, click model.Click) error
+	GetQRCode(shortURL string, opts model.QRCodeOptions) ([]byte, error)
+	GetLinksByUserID(id model.UserID, query model.UserLinksQuery) (model.LinkPage, error)
+	UpdateLink(id model.UserID, shortURL string, patch model.LinkPatch) (model.Link, error)
+	GetLinkHistory(id model.UserID, shortURL string) ([]model.LinkVersion, error)
+	RollbackLink(id model.UserID, shortURL string, version int) (model.Link, error)
 	DeleteShortURLs(id model.UserID, shortURls []string) error
+	RestoreShortURLs(id model.UserID, shortURLs []string) error
+	GetBlockedLinks() ([]model.BlockedLink, error)
+	ReportLink(shortURL string, reason string) (model.AbuseReport, error)
+	GetReports() ([]model.AbuseReport, error)
+	SetLinkDisabled(shortURL string, disabled bool) error
 	Ping() error
 }
 
 type LinkIDEncoder interface {
 	EncodeToString(id model.LinkID) (string, error)
+	// DecodeFromString Декодирует код, предварительно приведенный к каноническому виду (см. NormalizeCode).
 	DecodeFromString(str string) (model.LinkID, error)
+	// NormalizeCode Приводит код к каноническому виду: исправляет регистр и похожие символы (0 и o, 1 и l).
+	NormalizeCode(str string) string
 }
@@ -4,31 +4,59 @@ import (
 	"errors"
 	"github.com/ikashurnikov/shortener/internal/app/model"
 	"github.com/ikashurnikov/shortener/internal/app/repo"
+	"golang.org/x/exp/slices"
+	"net"
+	"net/http"
 	"net/url"
 	"strings"
 )
 
 type shortener struct {
-	linkIDEncoder  LinkIDEncoder
+	linkIDEncoder LinkIDEncoder
+	canonicalizer model.URLCanonicalization
+	rewriteRules  model.RewriteRules
+	destinations  model.DestinationPolicy
+	resolver      URLResolver
+	blocklist     *Blocklist
+	geoIP         *GeoIP
+	qrCodes       *QRCodeRenderer
+	// redirectStatus Статус перенаправления ссылок, для которых он не задан.
+	redirectStatus int
 	repo           repo.Repo
+	baseURL        url.URL
 	shortURLPrefix string
+	// rejectSelfLinks Отклонять короткие ссылки сервиса вместо замены их оригинальными ссылками.
+	rejectSelfLinks bool
 }
 
-func NewShortener(repo repo.Repo, baseURL url.URL) *shortener {
+func NewShortener(repo repo.Repo, baseURL url.URL, opts ...Option) *shortener {
 	shortURLPrefix := baseURL.String()
 	if !strings.HasSuffix(shortURLPrefix, "/") {
 		shortURLPrefix = shortURLPrefix + "/"
 	}
 
-	return &shortener{
+	s := &shortener{
 		repo:           repo,
 		linkIDEncoder:  NewZBase32LinkIDEncoder(),
+		canonicalizer:  model.DefaultURLCanonicalization,
+		destinations:   model.DefaultDestinationPolicy(),
+		qrCodes:        NewQRCodeRenderer(DefaultQRCodeCacheSize),
+		redirectStatus: http.StatusTemporaryRedirect,
+		baseURL:        baseURL,
 		shortURLPrefix: shortURLPrefix,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *shortener) CreateLink(userID *model.UserID, originalURL string) (model.Link, error) {
-	originalURL, err := model.NormalizeOriginalURL(originalURL)
+	if userID == nil {
+		return model.Link{}, model.ErrInvalidUserID
+	}
+
+	originalURL, err := s.prepareOriginalURL(*userID, originalURL)
 	if err != nil {
 		return model.Link{}, err
 	}
@@ -50,11 +78,19 @@ func (s *shortener) CreateLink(userID *model.UserID, originalURL string) (model.
 }
 
 func (s *shortener) CreateLinks(userID *model.UserID, originalURLs []string) ([]model.Link, error) {
-	originalURLs, err := model.NormalizeOriginalURLs(originalURLs)
-	if err != nil {
-		return nil, err
+	if userID == nil {
+		return nil, model.ErrInvalidUserID
 	}
 
+	prepared := make([]string, len(originalURLs))
+	for i, originalURL := range originalURLs {
+		var err error
+		if prepared[i], err = s.prepareOriginalURL(*userID, originalURL); err != nil {
+			return nil, err
+		}
+	}
+	originalURLs = prepared
+
 	if err := s.addUser(userID); err != nil {
 		return nil, err
 	}
@@ -76,8 +112,18 @@ func (s *shortener) CreateLinks(userID *model.UserID, originalURLs []string) ([]
 	return res, nil
 }
 
-func (s *shortener) GetLinkByShortURL(shortURL string) (model.Link, error) {
-	linkID, err := s.linkIDEncoder.DecodeFromString(shortURL)
+// GetLinkByShortURL Возвращает ссылку для перехода по коду: оригинальная ссылка выбирается правилами
+// перехода ссылки по параметрам visit (страна посетителя определяется по visit.IP, см. WithGeoIP),
+// а если ни одно правило не выполнено - вариантом ссылки для посетителя (Variant содержит имя варианта).
+// К оригинальной ссылке добавляются параметры правил Append, заголовок и описание берутся у автора ссылки.
+// Если статус перенаправления для ссылки не задан, RedirectStatus содержит статус по умолчанию
+// (см. WithDefaultRedirectStatus). Если код записан не в каноническом виде (другой регистр, похожие символы),
+// возвращает также ошибку ErrNonCanonicalCode, а ShortURL ссылки содержит канонический код.
+// Если выбранная оригинальная ссылка попала в список фишинговых адресов, возвращает ссылку
+// и ErrBlockedDestination.
+func (s *shortener) GetLinkByShortURL(shortURL string, visit model.Visit) (model.Link, error) {
+	code := s.linkIDEncoder.NormalizeCode(shortURL)
+	linkID, err := s.linkIDEncoder.DecodeFromString(code)
 	if err != nil {
 		return model.Link{}, err
 	}
@@ -87,32 +133,179 @@ func (s *shortener) GetLinkByShortURL(shortURL string) (model.Link, error) {
 		return model.Link{}, err
 	}
 
-	return s.createLink(linkID, origURL)
+	details, err := s.repo.GetLinkDetails(linkID)
+	if err != nil {
+		return model.Link{}, err
+	}
+	if visit.Country == "" && visit.IP != nil && s.geoIP != nil {
+		// База без адреса или с ошибкой не должна мешать переходу: страна остается неизвестной.
+		visit.Country, _ = s.geoIP.Country(visit.IP)
+	}
+
+	var variant string
+	if target, ok := details.Routing.Route(visit); ok {
+		origURL = target
+	} else if v, ok := details.Variants.Pick(linkID, visit.VisitorID); ok {
+		origURL, variant = v.OriginalURL, v.Name
+	}
+
+	if s.isBlocked(origURL) {
+		link, err := s.createLink(linkID, origURL)
+		if err != nil {
+			return model.Link{}, err
+		}
+		return link, model.ErrBlockedDestination
+	}
+
+	origURL, err = s.appendParams(linkID, origURL)
+	if err != nil {
+		return model.Link{}, err
+	}
+
+	link, err := s.createLink(linkID, origURL)
+	if err != nil {
+		return model.Link{}, err
+	}
+
+	link.Title = details.Title
+	link.Note = details.Note
+	link.Clicks = details.Clicks
+	link.Interstitial = details.Interstitial
+	link.RedirectStatus = details.RedirectStatus
+	link.Routing = details.Routing
+	link.Variants = details.Variants
+	link.Variant = variant
+	link.Country = visit.Country
+	link.CountryClicks = details.CountryClicks
+	if link.RedirectStatus == 0 {
+		link.RedirectStatus = s.redirectStatus
+	}
+
+	if code != shortURL {
+		link.ShortURL = s.shortURLPrefix + code
+		return link, model.ErrNonCanonicalCode
+	}
+	return link, nil
 }
 
-func (s *shortener) GetLinksByUserID(userID model.UserID) ([]model.Link, error) {
+func (s *shortener) GetLinksByUserID(userID model.UserID, query model.UserLinksQuery) (model.LinkPage, error) {
 	if !userID.IsValid() {
-		return nil, nil
+		return model.LinkPage{}, nil
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = DefaultPageSize
 	}
+	if query.Limit > MaxPageSize {
+		query.Limit = MaxPageSize
+	}
+	pageSize := query.Limit
 
-	origURLToLinkIDs, err := s.repo.GetOriginalURLsByUserID(userID)
+	// Запрашиваем на одну ссылку больше, чтобы узнать, есть ли следующая страница.
+	query.Limit++
+	userLinks, err := s.repo.GetUserLinks(userID, query)
 	if err != nil {
 		if errors.Is(err, model.ErrUserNotFound) {
-			return nil, nil
+			return model.LinkPage{}, nil
 		}
-		return nil, err
+		return model.LinkPage{}, err
 	}
 
-	res := make([]model.Link, 0, len(origURLToLinkIDs))
-	for origURL, linkID := range origURLToLinkIDs {
-		link, err := s.createLink(linkID, origURL)
+	var page model.LinkPage
+	if len(userLinks) > pageSize {
+		userLinks = userLinks[:pageSize]
+		page.NextCursor = model.EncodeCursor(userLinks[pageSize-1].ID)
+	}
+
+	page.Links = make([]model.Link, 0, len(userLinks))
+	for _, userLink := range userLinks {
+		link, err := s.createUserLink(userLink)
 		if err != nil {
-			return nil, err
+			return model.LinkPage{}, err
 		}
-		res = append(res, link)
+		page.Links = append(page.Links, link)
 	}
 
-	return res, err
+	return page, nil
+}
+
+func (s *shortener) UpdateLink(userID model.UserID, shortURL string, patch model.LinkPatch) (model.Link, error) {
+	if !userID.IsValid() {
+		return model.Link{}, model.ErrLinkNotFound
+	}
+
+	if err := patch.Normalize(); err != nil {
+		return model.Link{}, err
+	}
+	if patch.OriginalURL != nil {
+		originalURL, err := s.prepareOriginalURL(userID, *patch.OriginalURL)
+		if err != nil {
+			return model.Link{}, err
+		}
+		patch.OriginalURL = &originalURL
+	}
+	if patch.Routing != nil {
+		// Ссылки правил проходят те же проверки, что и оригинальная ссылка.
+		routing := slices.Clone(*patch.Routing)
+		for i := range routing {
+			originalURL, err := s.prepareOriginalURL(userID, routing[i].OriginalURL)
+			if err != nil {
+				return model.Link{}, err
+			}
+			routing[i].OriginalURL = originalURL
+		}
+		patch.Routing = &routing
+	}
+	if patch.Variants != nil {
+		variants := slices.Clone(*patch.Variants)
+		for i := range variants {
+			originalURL, err := s.prepareOriginalURL(userID, variants[i].OriginalURL)
+			if err != nil {
+				return model.Link{}, err
+			}
+			variants[i].OriginalURL = originalURL
+		}
+		patch.Variants = &variants
+	}
+
+	linkID, err := s.decodeCode(shortURL)
+	if err != nil {
+		return model.Link{}, err
+	}
+
+	userLink, err := s.repo.UpdateUserLink(userID, linkID, patch)
+	if err != nil {
+		return model.Link{}, err
+	}
+	return s.createUserLink(userLink)
+}
+
+func (s *shortener) GetLinkHistory(userID model.UserID, shortURL string) ([]model.LinkVersion, error) {
+	if !userID.IsValid() {
+		return nil, model.ErrLinkNotFound
+	}
+
+	linkID, err := s.decodeCode(shortURL)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetLinkHistory(userID, linkID)
+}
+
+// RollbackLink Возвращает ссылке оригинальную ссылку из указанной версии.
+// Откат не удаляет историю, а добавляет в нее новую версию.
+func (s *shortener) RollbackLink(userID model.UserID, shortURL string, version int) (model.Link, error) {
+	history, err := s.GetLinkHistory(userID, shortURL)
+	if err != nil {
+		return model.Link{}, err
+	}
+
+	idx := slices.IndexFunc(history, func(v model.LinkVersion) bool { return v.Version == version })
+	if idx == -1 {
+		return model.Link{}, model.ErrVersionNotFound
+	}
+
+	return s.UpdateLink(userID, shortURL, model.LinkPatch{OriginalURL: &history[idx].OriginalURL})
 }
 
 func (s *shortener) DeleteShortURLs(userID model.UserID, shortURls []string) error {
@@ -120,16 +313,130 @@ func (s *shortener) DeleteShortURLs(userID model.UserID, shortURls []string) err
 		return model.ErrUserNotFound
 	}
 
-	linkIDs := make([]model.LinkID, len(shortURls))
-	for i, shortURL := range shortURls {
-		linkID, err := s.linkIDEncoder.DecodeFromString(shortURL)
+	linkIDs, err := s.decodeShortURLs(shortURls)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.DeleteURLs(userID, linkIDs)
+}
+
+func (s *shortener) RestoreShortURLs(userID model.UserID, shortURLs []string) error {
+	if !userID.IsValid() {
+		return model.ErrUserNotFound
+	}
+
+	linkIDs, err := s.decodeShortURLs(shortURLs)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.RestoreURLs(userID, linkIDs)
+}
+
+// GetBlockedLinks Возвращает сохраненные ссылки, оригинальные ссылки которых, в том числе ссылки правил
+// перехода и вариантов, попали в список фишинговых и вредоносных адресов.
+func (s *shortener) GetBlockedLinks() ([]model.BlockedLink, error) {
+	res := make([]model.BlockedLink, 0)
+	if s.blocklist == nil {
+		return res, nil
+	}
+
+	err := s.repo.ForEachLink(func(linkID model.LinkID, originalURLs []string) error {
+		for _, originalURL := range originalURLs {
+			rule, ok := s.blocklist.Match(originalURL)
+			if !ok {
+				continue
+			}
+
+			shortURL, err := s.createShortURL(linkID)
+			if err != nil {
+				return err
+			}
+			res = append(res, model.BlockedLink{ShortURL: shortURL, OriginalURL: originalURL, Rule: rule})
+			return nil
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// CountClick Учитывает переход по короткой ссылке, на ее вариант и из страны посетителя
+// (см. model.Link.Variant и model.Link.Country).
+func (s *shortener) CountClick(shortURL string, click model.Click) error {
+	linkID, err := s.decodeCode(shortURL)
+	if err != nil {
+		return err
+	}
+	return s.repo.CountClick(linkID, click)
+}
+
+// GetQRCode Возвращает изображение QR-кода короткой ссылки. Код содержит каноническую короткую ссылку.
+func (s *shortener) GetQRCode(shortURL string, opts model.QRCodeOptions) ([]byte, error) {
+	linkID, err := s.decodeCode(shortURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Для удаленных и отключенных ссылок код не выдается.
+	if _, err = s.repo.GetOriginalURLByID(linkID); err != nil {
+		return nil, err
+	}
+
+	content, err := s.createShortURL(linkID)
+	if err != nil {
+		return nil, err
+	}
+	return s.qrCodes.Render(content, opts)
+}
+
+// ReportLink Сохраняет жалобу на ссылку для модераторов.
+func (s *shortener) ReportLink(shortURL string, reason string) (model.AbuseReport, error) {
+	reason, err := model.NormalizeReportReason(reason)
+	if err != nil {
+		return model.AbuseReport{}, err
+	}
+
+	linkID, err := s.decodeCode(shortURL)
+	if err != nil {
+		return model.AbuseReport{}, err
+	}
+
+	report, err := s.repo.AddReport(linkID, reason)
+	if err != nil {
+		return model.AbuseReport{}, err
+	}
+	return s.createAbuseReport(report)
+}
+
+// GetReports Возвращает очередь жалоб на ссылки в порядке поступления.
+func (s *shortener) GetReports() ([]model.AbuseReport, error) {
+	reports, err := s.repo.GetReports()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]model.AbuseReport, 0, len(reports))
+	for _, report := range reports {
+		abuseReport, err := s.createAbuseReport(report)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		linkIDs[i] = linkID
+		res = append(res, abuseReport)
 	}
+	return res, nil
+}
 
-	return s.repo.DeleteURLs(userID, linkIDs)
+// SetLinkDisabled Отключает ссылку для всех пользователей или включает ее обратно.
+func (s *shortener) SetLinkDisabled(shortURL string, disabled bool) error {
+	linkID, err := s.decodeCode(shortURL)
+	if err != nil {
+		return err
+	}
+	return s.repo.SetLinkDisabled(linkID, disabled)
 }
 
 func (s *shortener) Ping() error {
@@ -152,11 +459,174 @@ func (s *shortener) addUser(userID *model.UserID) error {
 	return nil
 }
 
+// prepareOriginalURL Приводит оригинальную ссылку пользователя к виду, в котором она сохраняется:
+// канонизирует ее, раскрывает ссылки других сокращателей и собственные короткие ссылки,
+// удаляет параметры отслеживания и проверяет допустимость.
+func (s *shortener) prepareOriginalURL(userID model.UserID, originalURL string) (string, error) {
+	originalURL, err := s.canonicalizer.Canonicalize(originalURL)
+	if err != nil {
+		return "", err
+	}
+
+	if s.resolver != nil {
+		if originalURL, err = s.resolver.Resolve(originalURL); err != nil {
+			return "", err
+		}
+		if originalURL, err = s.canonicalizer.Canonicalize(originalURL); err != nil {
+			return "", err
+		}
+	}
+
+	if originalURL, err = s.resolveSelfLink(originalURL); err != nil {
+		return "", err
+	}
+
+	originalURL = s.rewriteRules.StripParams(userID, originalURL)
+	if err = s.destinations.Check(originalURL); err != nil {
+		return "", err
+	}
+	if s.isBlocked(originalURL) {
+		return "", model.ErrBlockedDestination
+	}
+	return originalURL, nil
+}
+
+// resolveSelfLink Заменяет собственную короткую ссылку ее оригинальной ссылкой
+// или отклоняет ее, если задано WithRejectSelfLinks. Другие ссылки на хост сервиса отклоняются всегда.
+func (s *shortener) resolveSelfLink(originalURL string) (string, error) {
+	code, ok := s.selfLinkCode(originalURL)
+	if !ok {
+		return originalURL, nil
+	}
+	if s.rejectSelfLinks {
+		return "", model.ErrSelfLink
+	}
+
+	linkID, err := s.decodeCode(code)
+	if err != nil {
+		return "", model.ErrSelfLink
+	}
+
+	target, err := s.repo.GetOriginalURLByID(linkID)
+	if err != nil {
+		return "", model.ErrSelfLink
+	}
+
+	// Сохраненные ранее ссылки могли вести на сервис.
+	if _, ok = s.selfLinkCode(target); ok {
+		return "", model.ErrSelfLink
+	}
+	return target, nil
+}
+
+// selfLinkCode Проверяет, ведет ли ссылка на хост сервиса, и возвращает путь ссылки относительно BaseURL.
+func (s *shortener) selfLinkCode(originalURL string) (string, bool) {
+	u, err := url.Parse(originalURL)
+	if err != nil || !strings.EqualFold(hostWithPort(u), hostWithPort(&s.baseURL)) {
+		return "", false
+	}
+
+	basePath := strings.TrimSuffix(s.baseURL.Path, "/") + "/"
+	if !strings.HasPrefix(u.Path+"/", basePath) {
+		return "", false
+	}
+	return strings.TrimPrefix(u.Path, basePath), true
+}
+
+// hostWithPort Возвращает хост ссылки с портом, явно указывая порт по умолчанию.
+func hostWithPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch strings.ToLower(u.Scheme) {
+		case "https":
+			port = "443"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(strings.TrimSuffix(u.Hostname(), "."), port)
+}
+
+// isBlocked Проверяет оригинальную ссылку по списку фишинговых и вредоносных адресов.
+func (s *shortener) isBlocked(originalURL string) bool {
+	if s.blocklist == nil {
+		return false
+	}
+	_, ok := s.blocklist.Match(originalURL)
+	return ok
+}
+
+// appendParams Добавляет к оригинальной ссылке параметры правил Append с учетом автора ссылки.
+func (s *shortener) appendParams(linkID model.LinkID, originalURL string) (string, error) {
+	author := model.UserID(model.InvalidUserID)
+	if s.rewriteRules.HasUserRules() {
+		var err error
+		if author, err = s.repo.GetLinkAuthor(linkID); err != nil {
+			return "", err
+		}
+	}
+	return s.rewriteRules.AppendParams(author, originalURL), nil
+}
+
 func (s *shortener) createLink(linkID model.LinkID, originalURL string) (model.Link, error) {
 	shortURL, err := s.createShortURL(linkID)
 	return model.Link{OriginalURL: originalURL, ShortURL: shortURL}, err
 }
 
+// decodeCode Декодирует код короткой ссылки, записанный в любом виде (другой регистр, похожие символы).
+func (s *shortener) decodeCode(code string) (model.LinkID, error) {
+	return s.linkIDEncoder.DecodeFromString(s.linkIDEncoder.NormalizeCode(code))
+}
+
+func (s *shortener) decodeShortURLs(shortURLs []string) ([]model.LinkID, error) {
+	linkIDs := make([]model.LinkID, len(shortURLs))
+	for i, shortURL := range shortURLs {
+		linkID, err := s.decodeCode(shortURL)
+		if err != nil {
+			return nil, err
+		}
+		linkIDs[i] = linkID
+	}
+	return linkIDs, nil
+}
+
+func (s *shortener) createUserLink(userLink model.UserLink) (model.Link, error) {
+	link, err := s.createLink(userLink.ID, userLink.OriginalURL)
+	if err != nil {
+		return model.Link{}, err
+	}
+
+	link.CreatedAt = userLink.CreatedAt
+	link.Title = userLink.Title
+	link.Note = userLink.Note
+	link.Tags = userLink.Tags
+	link.DeletedAt = userLink.DeletedAt
+	link.Clicks = userLink.Clicks
+	link.CountryClicks = userLink.CountryClicks
+	link.Interstitial = userLink.Interstitial
+	link.RedirectStatus = userLink.RedirectStatus
+	link.Routing = userLink.Routing
+	link.Variants = userLink.Variants
+	link.QRCodeURL = link.ShortURL + "/qr.png"
+	return link, nil
+}
+
+func (s *shortener) createAbuseReport(report model.LinkReport) (model.AbuseReport, error) {
+	shortURL, err := s.createShortURL(report.LinkID)
+	if err != nil {
+		return model.AbuseReport{}, err
+	}
+
+	return model.AbuseReport{
+		ID:          report.ID,
+		ShortURL:    shortURL,
+		OriginalURL: report.OriginalURL,
+		Reason:      report.Reason,
+		CreatedAt:   report.CreatedAt,
+		Disabled:    report.Disabled,
+	}, nil
+}
+
 func (s *shortener) createShortURL(id model.LinkID) (string, error) {
 	shortURL, err := s.linkIDEncoder.EncodeToString(id)
 	if err != nil {
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+	"github.com/ikashurnikov/shortener/internal/app/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBaseURL Базовый адрес коротких ссылок сервиса в тестах.
+const testBaseURL = "http://short.example"
+
+// newTestShortener Создает сервис с хранилищем в памяти и базовым адресом testBaseURL.
+func newTestShortener(t *testing.T, opts ...Option) *shortener {
+	baseURL, err := url.Parse(testBaseURL)
+	require.NoError(t, err)
+	return NewShortener(repo.NewInMemoryRepo(), *baseURL, opts...)
+}
+
+// createCode Сокращает ссылку от имени пользователя userID (новый пользователь, если ID не задан)
+// и возвращает короткую ссылку и ее код.
+func createCode(t *testing.T, s *shortener, userID *model.UserID, originalURL string) (model.Link, string) {
+	link, err := s.CreateLink(userID, originalURL)
+	require.NoError(t, err)
+	return link, strings.TrimPrefix(link.ShortURL, testBaseURL+"/")
+}
+
+func TestShortener_Moderation(t *testing.T) {
+	s := newTestShortener(t)
+
+	userID := model.UserID(model.InvalidUserID)
+	link, code := createCode(t, s, &userID, "https://phishing.example/login")
+
+	for _, reason := range []string{"", "   ", strings.Repeat("x", model.MaxReportReasonLen+1)} {
+		_, err := s.ReportLink(code, reason)
+		assert.ErrorIs(t, err, model.ErrInvalidReport)
+	}
+
+	report, err := s.ReportLink(strings.ToUpper(code), "  phishing ")
+	require.NoError(t, err)
+	assert.Equal(t, link.ShortURL, report.ShortURL)
+	assert.Equal(t, link.OriginalURL, report.OriginalURL)
+	assert.Equal(t, "phishing", report.Reason)
+
+	require.NoError(t, s.SetLinkDisabled(code, true))
+	_, err = s.GetLinkByShortURL(code, model.Visit{})
+	assert.ErrorIs(t, err, model.ErrLinkDisabled)
+	_, err = s.GetQRCode(code, model.DefaultQRCodeOptions(model.QRCodePNG))
+	assert.ErrorIs(t, err, model.ErrLinkDisabled)
+
+	// Отключенная ссылка остается в списке пользователя.
+	page, err := s.GetLinksByUserID(userID, model.UserLinksQuery{})
+	require.NoError(t, err)
+	assert.Len(t, page.Links, 1)
+
+	reports, err := s.GetReports()
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.True(t, reports[0].Disabled)
+
+	require.NoError(t, s.SetLinkDisabled(code, false))
+	_, err = s.GetLinkByShortURL(code, model.Visit{})
+	assert.NoError(t, err)
+	_, err = s.GetQRCode(code, model.DefaultQRCodeOptions(model.QRCodePNG))
+	assert.NoError(t, err)
+}
+
+func TestShortener_LinkDetails(t *testing.T) {
+	s := newTestShortener(t)
+
+	userID := model.UserID(model.InvalidUserID)
+	_, code := createCode(t, s, &userID, "https://untrusted.example/download")
+
+	title, note, interstitial := "Download", "Installer of the app", true
+	updated, err := s.UpdateLink(userID, code, model.LinkPatch{Title: &title, Note: &note, Interstitial: &interstitial})
+	require.NoError(t, err)
+	assert.True(t, updated.Interstitial)
+
+	require.NoError(t, s.CountClick(code, model.Click{}))
+	require.NoError(t, s.CountClick(strings.ToUpper(code), model.Click{}))
+
+	got, err := s.GetLinkByShortURL(code, model.Visit{})
+	require.NoError(t, err)
+	assert.Equal(t, title, got.Title)
+	assert.Equal(t, note, got.Note)
+	assert.Equal(t, int64(2), got.Clicks)
+	assert.True(t, got.Interstitial)
+
+	// Свойства ссылки для всех пользователей меняет только единственный владелец.
+	other := model.UserID(model.InvalidUserID)
+	_, err = s.CreateLink(&other, "https://untrusted.example/download")
+	require.ErrorIs(t, err, model.ErrLinkAlreadyExists)
+	interstitial = false
+	_, err = s.UpdateLink(userID, code, model.LinkPatch{Interstitial: &interstitial})
+	assert.ErrorIs(t, err, model.ErrLinkShared)
+}
+
+func TestShortener_RedirectStatus(t *testing.T) {
+	s := newTestShortener(t, WithDefaultRedirectStatus(http.StatusFound))
+
+	userID := model.UserID(model.InvalidUserID)
+	_, code := createCode(t, s, &userID, "https://docs.example/latest")
+
+	got, err := s.GetLinkByShortURL(code, model.Visit{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusFound, got.RedirectStatus)
+
+	status := http.StatusMovedPermanently
+	updated, err := s.UpdateLink(userID, code, model.LinkPatch{RedirectStatus: &status})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusMovedPermanently, updated.RedirectStatus)
+
+	got, err = s.GetLinkByShortURL(code, model.Visit{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusMovedPermanently, got.RedirectStatus)
+
+	status = http.StatusOK
+	_, err = s.UpdateLink(userID, code, model.LinkPatch{RedirectStatus: &status})
+	assert.ErrorIs(t, err, model.ErrInvalidLinkPatch)
+}
+
+func TestShortener_Routing(t *testing.T) {
+	s := newTestShortener(t)
+
+	userID := model.UserID(model.InvalidUserID)
+	_, code := createCode(t, s, &userID, "https://app.example/")
+
+	routing := model.RoutingRules{
+		{Platforms: []model.Platform{model.PlatformIOS}, OriginalURL: "https://APPS.apple.com/app/id1"},
+		{Platforms: []model.Platform{model.PlatformAndroid}, OriginalURL: "https://play.google.com/store/apps/details?id=app"},
+	}
+	updated, err := s.UpdateLink(userID, code, model.LinkPatch{Routing: &routing})
+	require.NoError(t, err)
+	// Ссылки правил приводятся к каноническому виду, как и оригинальная ссылка.
+	assert.Equal(t, "https://apps.apple.com/app/id1", updated.Routing[0].OriginalURL)
+
+	got, err := s.GetLinkByShortURL(code, model.Visit{UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 16_5 like Mac OS X)"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://apps.apple.com/app/id1", got.OriginalURL)
+
+	got, err = s.GetLinkByShortURL(code, model.Visit{UserAgent: "Mozilla/5.0 (Linux; Android 13; Pixel 7)"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://play.google.com/store/apps/details?id=app", got.OriginalURL)
+
+	got, err = s.GetLinkByShortURL(code, model.Visit{UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64)"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://app.example/", got.OriginalURL)
+
+	invalid := model.RoutingRules{{OriginalURL: "javascript:alert(1)"}}
+	_, err = s.UpdateLink(userID, code, model.LinkPatch{Routing: &invalid})
+	assert.ErrorIs(t, err, model.ErrForbiddenDestination)
+}
+
+func TestShortener_Variants(t *testing.T) {
+	s := newTestShortener(t)
+
+	userID := model.UserID(model.InvalidUserID)
+	link, code := createCode(t, s, &userID, "https://landing.example/")
+
+	variants := model.Variants{
+		{Name: "a", OriginalURL: "https://landing.example/a", Weight: 70},
+		{Name: "b", OriginalURL: "https://landing.example/b", Weight: 30},
+	}
+	_, err := s.UpdateLink(userID, code, model.LinkPatch{Variants: &variants})
+	require.NoError(t, err)
+
+	for visitorID := uint32(0); visitorID < 100; visitorID++ {
+		got, err := s.GetLinkByShortURL(code, model.Visit{VisitorID: visitorID})
+		require.NoError(t, err)
+		require.NotEmpty(t, got.Variant)
+		assert.Equal(t, "https://landing.example/"+got.Variant, got.OriginalURL)
+		require.NoError(t, s.CountClick(code, model.Click{Variant: got.Variant}))
+	}
+
+	// Веса меняются без изменения кода, переходы по вариантам сохраняются.
+	variants = model.Variants{
+		{Name: "a", OriginalURL: "https://landing.example/a", Weight: 0},
+		{Name: "b", OriginalURL: "https://landing.example/b", Weight: 1},
+	}
+	updated, err := s.UpdateLink(userID, code, model.LinkPatch{Variants: &variants})
+	require.NoError(t, err)
+	assert.Equal(t, link.ShortURL, updated.ShortURL)
+	assert.Equal(t, int64(100), updated.Clicks)
+	assert.Equal(t, int64(100), updated.Variants[0].Clicks+updated.Variants[1].Clicks)
+	assert.NotZero(t, updated.Variants[0].Clicks)
+
+	got, err := s.GetLinkByShortURL(code, model.Visit{VisitorID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "b", got.Variant)
+
+	// Правила перехода применяются раньше вариантов.
+	routing := model.RoutingRules{{QueryParam: "app", OriginalURL: "https://app.example/"}}
+	_, err = s.UpdateLink(userID, code, model.LinkPatch{Routing: &routing})
+	require.NoError(t, err)
+	got, err = s.GetLinkByShortURL(code, model.Visit{Query: url.Values{"app": {""}}})
+	require.NoError(t, err)
+	assert.Equal(t, "https://app.example/", got.OriginalURL)
+	assert.Empty(t, got.Variant)
+}
+
+// canonicalOnlyEncoder Декодирует только коды в каноническом виде, как допускает LinkIDEncoder.DecodeFromString.
+type canonicalOnlyEncoder struct {
+	LinkIDEncoder
+}
+
+func (e canonicalOnlyEncoder) DecodeFromString(str string) (model.LinkID, error) {
+	if e.NormalizeCode(str) != str {
+		return 0, model.ErrDecodingShortURL
+	}
+	return e.LinkIDEncoder.DecodeFromString(str)
+}
+
+func TestShortener_NonCanonicalCodes(t *testing.T) {
+	encoder := canonicalOnlyEncoder{NewZBase32LinkIDEncoder()}
+	s := newTestShortener(t, WithLinkIDEncoder(encoder))
+
+	userID := model.UserID(model.InvalidUserID)
+	link, code := createCode(t, s, &userID, "https://docs.example/v1")
+	upper := strings.ToUpper(code)
+	require.NotEqual(t, code, upper)
+
+	t.Run("UpdateLink", func(t *testing.T) {
+		originalURL := "https://docs.example/v2"
+		updated, err := s.UpdateLink(userID, upper, model.LinkPatch{OriginalURL: &originalURL})
+		require.NoError(t, err)
+		assert.Equal(t, link.ShortURL, updated.ShortURL)
+		assert.Equal(t, originalURL, updated.OriginalURL)
+	})
+
+	t.Run("GetLinkHistory", func(t *testing.T) {
+		history, err := s.GetLinkHistory(userID, upper)
+		require.NoError(t, err)
+		assert.Len(t, history, 2)
+	})
+
+	t.Run("RollbackLink", func(t *testing.T) {
+		updated, err := s.RollbackLink(userID, upper, 1)
+		require.NoError(t, err)
+		assert.Equal(t, "https://docs.example/v1", updated.OriginalURL)
+	})
+
+	t.Run("DeleteShortURLs", func(t *testing.T) {
+		require.NoError(t, s.DeleteShortURLs(userID, []string{upper}))
+		_, err := s.GetLinkByShortURL(code, model.Visit{})
+		assert.ErrorIs(t, err, model.ErrLinkRemoved)
+	})
+
+	t.Run("RestoreShortURLs", func(t *testing.T) {
+		require.NoError(t, s.RestoreShortURLs(userID, []string{upper}))
+		_, err := s.GetLinkByShortURL(code, model.Visit{})
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+)
+
+// DefaultMaxRedirects Максимальное кол-во переходов при раскрытии ссылки.
+const DefaultMaxRedirects = 5
+
+// URLResolver Возвращает ссылку, на которую в итоге ведет оригинальная ссылка.
+type URLResolver interface {
+	Resolve(originalURL string) (string, error)
+}
+
+// ShortenerResolver Раскрывает ссылки известных сокращателей, проходя по перенаправлениям,
+// пока ссылка ведет на домен сокращателя.
+//
+// Запросы отправляются только на домены сокращателей. Если сокращатель недоступен или не перенаправляет,
+// возвращается последняя полученная ссылка. Зацикливание и слишком длинная цепочка перенаправлений
+// приводят к ошибке model.ErrRedirectLoop.
+type ShortenerResolver struct {
+	client       http.Client
+	domains      []string
+	maxRedirects int
+}
+
+// NewShortenerResolver Создает раскрыватель ссылок доменов domains, отправляющий запросы клиентом client.
+// Переходы по перенаправлениям выполняет сам раскрыватель, поэтому CheckRedirect клиента не используется.
+func NewShortenerResolver(client *http.Client, domains []string) *ShortenerResolver {
+	r := &ShortenerResolver{
+		client:       *client,
+		maxRedirects: DefaultMaxRedirects,
+	}
+	for _, domain := range domains {
+		r.domains = append(r.domains, strings.ToLower(domain))
+	}
+	r.client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return r
+}
+
+func (r *ShortenerResolver) Resolve(originalURL string) (string, error) {
+	visited := make(map[string]bool)
+	for hops := 0; r.isShortener(originalURL); hops++ {
+		if visited[originalURL] || hops == r.maxRedirects {
+			return "", model.ErrRedirectLoop
+		}
+		visited[originalURL] = true
+
+		next, ok := r.next(originalURL)
+		if !ok {
+			break
+		}
+		originalURL = next
+	}
+	return originalURL, nil
+}
+
+// next Возвращает ссылку, на которую перенаправляет originalURL.
+func (r *ShortenerResolver) next(originalURL string) (string, bool) {
+	resp, err := r.client.Head(originalURL)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = r.client.Get(originalURL)
+	}
+	if err != nil {
+		return "", false
+	}
+	defer resp.Body.Close()
+
+	location, err := resp.Location()
+	if err != nil || !isRedirect(resp.StatusCode) {
+		return "", false
+	}
+	return location.String(), true
+}
+
+func (r *ShortenerResolver) isShortener(originalURL string) bool {
+	u, err := url.Parse(originalURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for _, domain := range r.domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+	"github.com/ikashurnikov/shortener/internal/app/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestShortenerServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(rw http.ResponseWriter, req *http.Request) {
+		http.Redirect(rw, req, "/b", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/b", func(rw http.ResponseWriter, req *http.Request) {
+		http.Redirect(rw, req, "https://yandex.ru/final?id=1", http.StatusFound)
+	})
+	mux.HandleFunc("/get-only", func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		http.Redirect(rw, req, "https://yandex.ru/get", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(rw http.ResponseWriter, req *http.Request) {
+		http.Redirect(rw, req, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/page", func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestShortenerResolver_Resolve(t *testing.T) {
+	server := newTestShortenerServer(t)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	resolver := NewShortenerResolver(server.Client(), []string{u.Hostname()})
+
+	got, err := resolver.Resolve(server.URL + "/a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://yandex.ru/final?id=1", got)
+
+	got, err = resolver.Resolve(server.URL + "/get-only")
+	require.NoError(t, err)
+	assert.Equal(t, "https://yandex.ru/get", got)
+
+	// Сокращатель не перенаправляет.
+	got, err = resolver.Resolve(server.URL + "/page")
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/page", got)
+
+	_, err = resolver.Resolve(server.URL + "/loop")
+	assert.ErrorIs(t, err, model.ErrRedirectLoop)
+
+	// Ссылки на другие домены не запрашиваются.
+	got, err = resolver.Resolve("https://example.com/a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/a", got)
+}
+
+func TestShortener_ResolveShortenerLinks(t *testing.T) {
+	server := newTestShortenerServer(t)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	s := newTestShortener(t, WithURLResolver(NewShortenerResolver(server.Client(), []string{u.Hostname()})))
+
+	userID := model.UserID(model.InvalidUserID)
+	link, err := s.CreateLink(&userID, server.URL+"/a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://yandex.ru/final?id=1", link.OriginalURL)
+
+	_, err = s.CreateLink(&userID, server.URL+"/loop")
+	assert.ErrorIs(t, err, model.ErrRedirectLoop)
+}
+
+func TestShortener_SelfLinks(t *testing.T) {
+	baseURL, err := url.Parse("http://short.example:80/s")
+	require.NoError(t, err)
+	s := NewShortener(repo.NewInMemoryRepo(), *baseURL)
+
+	userID := model.UserID(model.InvalidUserID)
+	link, err := s.CreateLink(&userID, "https://yandex.ru/")
+	require.NoError(t, err)
+	assert.Equal(t, "http://short.example:80/s/", link.ShortURL[:len("http://short.example:80/s/")])
+
+	// Короткая ссылка сервиса заменяется оригинальной.
+	selfLink, err := s.CreateLink(&userID, "HTTP://SHORT.example"+link.ShortURL[len("http://short.example:80"):])
+	require.ErrorIs(t, err, model.ErrLinkAlreadyExists)
+	assert.Equal(t, link, selfLink)
+
+	for _, originalURL := range []string{"http://short.example/s/", "http://short.example/s/unknown", "http://short.example/s/api/shorten"} {
+		_, err = s.CreateLink(&userID, originalURL)
+		assert.ErrorIs(t, err, model.ErrSelfLink, originalURL)
+	}
+
+	// Другие пути и порты хоста сервиса не считаются его ссылками.
+	_, err = s.CreateLink(&userID, "http://short.example/other")
+	assert.NoError(t, err)
+	_, err = s.CreateLink(&userID, "http://short.example:8080/s/")
+	assert.NoError(t, err)
+
+	reject := NewShortener(repo.NewInMemoryRepo(), *baseURL, WithRejectSelfLinks(true))
+	_, err = reject.CreateLink(&userID, link.ShortURL)
+	assert.ErrorIs(t, err, model.ErrSelfLink)
+}
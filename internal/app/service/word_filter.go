@@ -0,0 +1,115 @@
+package service
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"strings"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+)
+
+//go:embed blocked_words.txt
+var defaultBlockedWords string
+
+// leetClasses Символы, которыми в кодах может быть записана буква.
+// Похожие друг на друга буквы (i и l) попадают в один класс.
+var leetClasses = map[byte]byte{
+	'4': 'a', '@': 'a',
+	'8': 'b',
+	'3': 'e',
+	'6': 'g', '9': 'g',
+	'1': 'i', 'l': 'i', '!': 'i', '|': 'i',
+	'0': 'o',
+	'5': 's', '$': 's',
+	'7': 't', '+': 't',
+	'2': 'z',
+}
+
+// WordFilter Фильтр кодов коротких ссылок.
+//
+// Зарезервированные слова запрещают только совпадающий с ними код (без учета регистра),
+// запрещенные слова - любой код, содержащий слово, в том числе записанное leetspeak (sh1t, 5h!t).
+type WordFilter struct {
+	reserved map[string]struct{}
+	blocked  []string
+}
+
+func NewWordFilter(reserved []string, blocked []string) *WordFilter {
+	f := &WordFilter{reserved: make(map[string]struct{}, len(reserved))}
+	for _, word := range reserved {
+		if word = strings.TrimSpace(word); word != "" {
+			f.reserved[strings.ToLower(word)] = struct{}{}
+		}
+	}
+	f.AddBlockedWords(blocked...)
+	return f
+}
+
+// DefaultBlockedWords Возвращает встроенный список запрещенных слов.
+func DefaultBlockedWords() []string {
+	var words []string
+	_ = readWords(strings.NewReader(defaultBlockedWords), func(word string) { words = append(words, word) })
+	return words
+}
+
+// AddBlockedWords Добавляет запрещенные слова.
+func (f *WordFilter) AddBlockedWords(words ...string) {
+	for _, word := range words {
+		if word = unleet(strings.TrimSpace(word)); word != "" {
+			f.blocked = append(f.blocked, word)
+		}
+	}
+}
+
+// ReadBlockedWords Добавляет запрещенные слова из списка по одному слову в строке.
+// Пустые строки и строки, начинающиеся с #, пропускаются.
+func (f *WordFilter) ReadBlockedWords(r io.Reader) error {
+	return readWords(r, func(word string) { f.AddBlockedWords(word) })
+}
+
+// Check Проверяет код короткой ссылки. Возвращает model.ErrBlockedWord, если код запрещен.
+func (f *WordFilter) Check(code string) error {
+	if _, ok := f.reserved[strings.ToLower(code)]; ok {
+		return model.ErrBlockedWord
+	}
+
+	normalized := unleet(code)
+	for _, word := range f.blocked {
+		if strings.Contains(normalized, word) {
+			return model.ErrBlockedWord
+		}
+	}
+	return nil
+}
+
+// LinkIDFilter Возвращает фильтр для repo.WithLinkIDFilter, пропускающий ID, код которых разрешен.
+func (f *WordFilter) LinkIDFilter(encoder LinkIDEncoder) func(model.LinkID) bool {
+	return func(linkID model.LinkID) bool {
+		code, err := encoder.EncodeToString(linkID)
+		return err != nil || f.Check(code) == nil
+	}
+}
+
+func readWords(r io.Reader, add func(word string)) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		add(line)
+	}
+	return scanner.Err()
+}
+
+// unleet Приводит строку к нижнему регистру и заменяет символы leetspeak буквами.
+func unleet(str string) string {
+	buf := []byte(strings.ToLower(str))
+	for i, c := range buf {
+		if letter, ok := leetClasses[c]; ok {
+			buf[i] = letter
+		}
+	}
+	return string(buf)
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ikashurnikov/shortener/internal/app/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWordFilter_Check(t *testing.T) {
+	filter := NewWordFilter([]string{"api", "ping"}, []string{"shit", "fuck"})
+
+	tests := []struct {
+		code    string
+		blocked bool
+	}{
+		{code: "api", blocked: true},
+		{code: "PING", blocked: true},
+		{code: "apiX", blocked: false},
+		{code: "ybndrfg", blocked: false},
+		{code: "xshitx", blocked: true},
+		{code: "sh1t", blocked: true},
+		{code: "5HlT", blocked: true},
+		{code: "5h!t", blocked: true},
+		// 4 заменяет букву a, а не u.
+		{code: "F4CK0", blocked: false},
+		{code: "fuCKed", blocked: true},
+		{code: "shot", blocked: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			err := filter.Check(tt.code)
+			if tt.blocked {
+				assert.ErrorIs(t, err, model.ErrBlockedWord)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestWordFilter_ReadBlockedWords(t *testing.T) {
+	filter := NewWordFilter(nil, nil)
+	require.NoError(t, filter.ReadBlockedWords(strings.NewReader("# comment\n\n  b00b \nnope\n")))
+
+	assert.ErrorIs(t, filter.Check("xboobx"), model.ErrBlockedWord)
+	assert.ErrorIs(t, filter.Check("n0pe"), model.ErrBlockedWord)
+	assert.NoError(t, filter.Check("comment"))
+
+	assert.NotEmpty(t, DefaultBlockedWords())
+	assert.NotContains(t, DefaultBlockedWords(), "")
+}
+
+func TestWordFilter_LinkIDFilter(t *testing.T) {
+	encoder, err := NewBaseNLinkIDEncoder(Base62Alphabet)
+	require.NoError(t, err)
+
+	id, err := encoder.DecodeFromString("ping")
+	require.NoError(t, err)
+
+	accept := NewWordFilter([]string{"ping"}, []string{"fuck"}).LinkIDFilter(encoder)
+	assert.False(t, accept(id))
+	assert.True(t, accept(id+1))
+
+	id, err = encoder.DecodeFromString("xFUCK")
+	require.NoError(t, err)
+	assert.False(t, accept(id))
+}
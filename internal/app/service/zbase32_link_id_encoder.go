@@ -6,31 +6,72 @@ import (
 	"github.com/ikashurnikov/shortener/internal/app/model"
 )
 
+const (
+	// ZBase32CodeLen Длина кодов ZBase32LinkIDEncoder для 32-битных ID.
+	ZBase32CodeLen = 7
+	// zbase32LongCodeLen Длина кодов ZBase32LinkIDEncoder для 64-битных ID.
+	zbase32LongCodeLen = 13
+	// ZBase32Alphabet Алфавит кодов ZBase32LinkIDEncoder.
+	ZBase32Alphabet = "ybndrfg8ejkmcpqxot1uwisza345h769"
+)
+
+// ZBase32LinkIDEncoder Кодирует ID ссылки в формате z-base-32.
+// Коды декодируются без учета регистра, похожие символы (0, l) заменяются символами алфавита (o, 1).
 type ZBase32LinkIDEncoder struct {
-	impl *zbase32.Encoding
+	impl     *zbase32.Encoding
+	alphabet *alphabet
 }
 
 func NewZBase32LinkIDEncoder() *ZBase32LinkIDEncoder {
+	a, _ := newAlphabet(ZBase32Alphabet)
 	return &ZBase32LinkIDEncoder{
-		impl: zbase32.StdEncoding,
+		impl:     zbase32.StdEncoding,
+		alphabet: a,
 	}
 }
 
+// EncodeToString Кодирует ID ссылки. ID, не превосходящие model.LegacyMaxLinkID, кодируются
+// 4 байтами (ZBase32CodeLen символов), как и до перехода на 64-битные ID, остальные - 8 байтами.
 func (e *ZBase32LinkIDEncoder) EncodeToString(linkID model.LinkID) (string, error) {
-	var bytes [4]byte
-	binary.LittleEndian.PutUint32(bytes[:], uint32(linkID))
+	if linkID <= model.LegacyMaxLinkID {
+		var bytes [4]byte
+		binary.LittleEndian.PutUint32(bytes[:], uint32(linkID))
+		return e.impl.EncodeToString(bytes[:]), nil
+	}
+
+	var bytes [8]byte
+	binary.LittleEndian.PutUint64(bytes[:], uint64(linkID))
 	return e.impl.EncodeToString(bytes[:]), nil
 }
 
 func (e *ZBase32LinkIDEncoder) DecodeFromString(str string) (model.LinkID, error) {
-	bytes, err := zbase32.StdEncoding.DecodeString(str)
+	// Библиотека декодирует и коды другой длины, например 6 символов в 4 байта,
+	// что привело бы к декодированию кодов других форматов.
+	if len(str) != ZBase32CodeLen && len(str) != zbase32LongCodeLen {
+		return 0, model.ErrDecodingShortURL
+	}
+
+	bytes, err := e.impl.DecodeString(e.NormalizeCode(str))
 	if err != nil {
 		return 0, err
 	}
 
-	if len(bytes) != 4 {
-		return 0, model.ErrDecodingShortURL
+	switch len(bytes) {
+	case 4:
+		return model.LinkID(binary.LittleEndian.Uint32(bytes)), nil
+
+	case 8:
+		// ID, помещающиеся в 4 байта, всегда кодируются 4 байтами.
+		linkID := model.LinkID(binary.LittleEndian.Uint64(bytes))
+		if linkID <= model.LegacyMaxLinkID || linkID > model.MaxLinkID {
+			return 0, model.ErrDecodingShortURL
+		}
+		return linkID, nil
 	}
 
-	return model.LinkID(binary.LittleEndian.Uint32(bytes)), nil
+	return 0, model.ErrDecodingShortURL
+}
+
+func (e *ZBase32LinkIDEncoder) NormalizeCode(str string) string {
+	return e.alphabet.normalize(str)
 }
@@ -38,6 +38,36 @@ func TestZBase32LinkIDEncoder_DecodeFromString(t *testing.T) {
 			want:    0,
 			wantErr: true,
 		},
+		{
+			name:    "decoding 64-bit id",
+			str:     "yyyyyyybyyyyy",
+			want:    0x100000000,
+			wantErr: false,
+		},
+		{
+			name:    "decoding non-canonical 64-bit id",
+			str:     "999999ayyyyyy",
+			want:    0,
+			wantErr: true,
+		},
+		{
+			name:    "decoding id out of range",
+			str:     "9999999999999",
+			want:    0,
+			wantErr: true,
+		},
+		{
+			name:    "decoding upper case string",
+			str:     "999999A",
+			want:    0xffffffff,
+			wantErr: false,
+		},
+		{
+			name:    "decoding string with confusable symbols",
+			str:     "ybndrf0",
+			want:    0x16324400,
+			wantErr: false,
+		},
 		{
 			name:    "decoding string with invalid symbols",
 			str:     "[][]",
@@ -72,6 +102,10 @@ func TestZBase32LinkIDEncoder_EncodeToString(t *testing.T) {
 			value: 0xffffffff,
 			want:  "999999a",
 		},
+		{
+			value: 0x100000000,
+			want:  "yyyyyyybyyyyy",
+		},
 	}
 	for _, tt := range tests {
 		name := fmt.Sprintf("encodig %v", tt.value)
@@ -83,3 +117,11 @@ func TestZBase32LinkIDEncoder_EncodeToString(t *testing.T) {
 		})
 	}
 }
+
+func TestZBase32LinkIDEncoder_NormalizeCode(t *testing.T) {
+	encoder := NewZBase32LinkIDEncoder()
+	assert.Equal(t, "ybndrfo", encoder.NormalizeCode("YBNDRF0"))
+	assert.Equal(t, "t1u1", encoder.NormalizeCode("tlUL"))
+	assert.Equal(t, "999999a", encoder.NormalizeCode("999999a"))
+	assert.Equal(t, "[][]", encoder.NormalizeCode("[][]"))
+}